@@ -3,17 +3,23 @@ module github.com/NovatecConsulting/grafana-dashboard-sync
 go 1.16
 
 require (
+	github.com/Masterminds/semver/v3 v3.1.1
+	github.com/Microsoft/go-winio v0.4.16 // indirect
 	github.com/NovatecConsulting/grafana-api-go-sdk v0.0.0-20220202161647-eb8dd86d92dc
-	github.com/go-git/go-git/v5 v5.4.2
-	github.com/golobby/config v1.2.0
-	github.com/golobby/config/v3 v3.3.1
-	github.com/gosimple/slug v1.11.2 // indirect
+	github.com/ProtonMail/go-crypto v0.0.0-20210428141323-04723f9f07d7
+	github.com/google/go-jsonnet v0.18.0
+	github.com/kevinburke/ssh_config v0.0.0-20201106050909-4977a11b4351 // indirect
+	github.com/kr/text v0.2.0 // indirect
 	github.com/magefile/mage v1.11.0
-	github.com/sergi/go-diff v1.2.0 // indirect
 	github.com/sirupsen/logrus v1.8.1
-	github.com/urfave/cli/v2 v2.3.0 // indirect
+	github.com/urfave/cli/v2 v2.3.0
+	github.com/xanzy/ssh-agent v0.3.0 // indirect
 	golang.org/x/crypto v0.0.0-20210421170649-83a5a9bb288b
+	golang.org/x/net v0.0.0-20210326060303-6b1517762897 // indirect
+	golang.org/x/sys v0.0.0-20210630005230-0f9fa26af87c
+	gopkg.in/check.v1 v1.0.0-20201130134442-10cb98267c6c // indirect
 	gopkg.in/src-d/go-billy.v4 v4.3.2
 	gopkg.in/src-d/go-git.v4 v4.13.1
-	gopkg.in/yaml.v3 v3.0.0-20210107192922-496545a6307b // indirect
+	gopkg.in/yaml.v2 v2.3.0 // indirect
+	gopkg.in/yaml.v3 v3.0.0-20210107192922-496545a6307b
 )
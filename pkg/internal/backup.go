@@ -0,0 +1,123 @@
+package internal
+
+import (
+	"encoding/json"
+	"path"
+	"strings"
+
+	sdk "github.com/NovatecConsulting/grafana-api-go-sdk"
+	log "github.com/sirupsen/logrus"
+)
+
+// the name of the file containing the metadata of a folder, followed by the extension of the exported files
+const folderMetadataFile = ".folder"
+
+// the metadata of a folder exported by a backup, which does not contain fields specific to the Grafana instance
+type folderMetadata struct {
+	UID   string `json:"uid"`
+	Title string `json:"title"`
+}
+
+// Checks whether the file with the given name contains the metadata of a folder.
+func isFolderMetadataFile(fileName string) bool {
+	return strings.TrimSuffix(fileName, path.Ext(fileName)) == folderMetadataFile
+}
+
+// Adds a metadata file for each folder of the Grafana instance to the given files.
+func (s *Synchronization) addFolderMetadataFiles(files map[string]ExportFile, fileExtension string) error {
+	folders, err := s.grafanaApi.GetAllFolders()
+	if err != nil {
+		return err
+	}
+
+	for _, folder := range folders {
+		metadataJson, err := json.Marshal(folderMetadata{UID: folder.UID, Title: folder.Title})
+		if err != nil {
+			return err
+		}
+		content, err := s.formatExportFile(metadataJson)
+		if err != nil {
+			return err
+		}
+
+		filePath := folder.Title + "/" + folderMetadataFile + fileExtension
+		files[filePath] = ExportFile{
+			Dashboard: filePath,
+			Content:   content,
+			UpdatedBy: folder.UpdatedBy,
+		}
+	}
+	return nil
+}
+
+// Returns the paths of all dashboards of the Grafana instance, whose files are kept by a backup although they may not be
+// selected. The given search results are used in case the search has not been restricted by the selection.
+func (s *Synchronization) backupDashboardPaths(foundBoards []sdk.FoundBoard, complete bool, fileExtension string) (map[string]bool, error) {
+	if !complete {
+		var err error
+		foundBoards, err = s.grafanaApi.SearchDashboards(nil, false)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	paths := make(map[string]bool, len(foundBoards))
+	for _, board := range foundBoards {
+		folderTitle := board.FolderTitle
+		if folderTitle == "" {
+			folderTitle = "General"
+		}
+		dashboardPath := folderTitle + "/" + board.Title
+		if s.options.PushConfiguration.Layout != "exploded" {
+			dashboardPath += fileExtension
+		}
+		paths[dashboardPath] = true
+	}
+	return paths, nil
+}
+
+// Checks whether the given file of the repository is kept by a backup. Besides the exported files, the files of all
+// dashboards existing in Grafana, the synchronization state and the Jsonnet libraries are kept.
+func (s *Synchronization) keepBackupFile(filePath string, files map[string]ExportFile, backupDashboards map[string]bool) bool {
+	if _, exported := files[filePath]; exported || backupDashboards[dashboardOfFile(filePath)] {
+		return true
+	}
+	if s.options.SyncState.Storage == "repository" && filePath == path.Clean(s.options.SyncState.path()) {
+		return true
+	}
+	return s.options.PullConfiguration.Jsonnet.isLibraryFile(filePath)
+}
+
+// Returns the given dashboard paths without the paths of folder metadata files.
+func withoutFolderMetadata(paths []string) []string {
+	var result []string
+	for _, dashboardPath := range paths {
+		if !isFolderMetadataFile(path.Base(dashboardPath)) {
+			result = append(result, dashboardPath)
+		}
+	}
+	return result
+}
+
+// Returns the UID of the given folder recorded in the worktree by a backup, empty in case it is unknown.
+func (s *Synchronization) folderUID(folderName string) string {
+	for _, extension := range dashboardFileExtensions {
+		metadataFile := folderName + "/" + folderMetadataFile + extension
+		content, err := s.gitApi.ReadDashboard(metadataFile, s.options.PullConfiguration.Jsonnet)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"file":  metadataFile,
+				"error": err,
+			}).Warn("Failed to read the folder metadata.")
+		}
+		if content == nil {
+			continue
+		}
+
+		var metadata folderMetadata
+		if json.Unmarshal(content, &metadata) == nil {
+			return metadata.UID
+		}
+	}
+	return ""
+}
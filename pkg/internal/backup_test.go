@@ -0,0 +1,69 @@
+package internal
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestBackupRemovesOnlyFilesOfDeletedDashboards(t *testing.T) {
+	grafana := startFakeGrafana(t)
+	grafana.addDashboard("Team", "selected", "Selected", "team")
+	grafana.addDashboard("Team", "excluded", "Excluded", "team")
+	grafana.addDashboard("Team", "untagged", "Untagged")
+
+	remote := newTestRemote(t)
+	remote.commitFiles(t, "main", map[string]string{
+		"Team/Excluded.json":             `{"title": "Excluded", "uid": "excluded"}`,
+		"Team/Untagged.json":             `{"title": "Untagged", "uid": "untagged"}`,
+		"Team/Deleted.json":              `{"title": "Deleted", "uid": "deleted"}`,
+		"Team/README.md":                 "Dashboards of the team",
+		"Old/.folder.json":               `{"title": "Old", "uid": "folder-old"}`,
+		"Old/Deleted.json":               `{"title": "Deleted", "uid": "old-deleted"}`,
+		"config/state.json":              `{"jobs": {"other": {"push": {}, "pull": {}}}}`,
+		"vendor/grafonnet/lib.libsonnet": "{}",
+		"vendor/example.json":            `{"title": "Example"}`,
+	})
+
+	synchronize(t, SynchronizeOptions{
+		JobName:          "backup",
+		GrafanaToken:     "token",
+		GrafanaUrl:       grafana.url,
+		GitRepositoryUrl: remote.url,
+		PrivateKeyFile:   remote.keyFile,
+		SyncState:        StateConfiguration{Storage: "repository", Path: "config/state.json"},
+		PushConfiguration: PushConfiguration{
+			SyncConfiguration: SyncConfiguration{
+				Enable:    true,
+				GitBranch: "main",
+				Selection: SelectionConfiguration{Tags: []string{"team"}, Exclude: "Excluded$"},
+			},
+			Backup: true,
+		},
+		PullConfiguration: PullConfiguration{
+			Jsonnet: JsonnetConfiguration{LibraryPaths: []string{"vendor"}},
+		},
+	})
+
+	files := remote.files(t, "main")
+	for _, filePath := range []string{"Team/Selected.json", "Team/.folder.json"} {
+		if _, ok := files[filePath]; !ok {
+			t.Errorf("expected %s to be exported", filePath)
+		}
+	}
+	for _, filePath := range []string{"Team/Deleted.json", "Old/.folder.json", "Old/Deleted.json"} {
+		if _, ok := files[filePath]; ok {
+			t.Errorf("expected %s of a deleted dashboard or folder to be removed", filePath)
+		}
+	}
+	for _, filePath := range []string{"Team/Excluded.json", "Team/Untagged.json", "Team/README.md", "vendor/grafonnet/lib.libsonnet", "vendor/example.json"} {
+		if _, ok := files[filePath]; !ok {
+			t.Errorf("expected %s to be kept", filePath)
+		}
+	}
+	if !strings.Contains(files["Team/Excluded.json"], `"uid": "excluded"`) {
+		t.Errorf("expected the file of the excluded dashboard to be unchanged, got %s", files["Team/Excluded.json"])
+	}
+	if state := files["config/state.json"]; !strings.Contains(state, `"other"`) || !strings.Contains(state, `"backup"`) {
+		t.Errorf("expected the state of both jobs to be kept, got %s", state)
+	}
+}
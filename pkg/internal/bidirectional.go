@@ -0,0 +1,48 @@
+package internal
+
+import (
+	"errors"
+)
+
+// Validates the configuration of a bidirectional synchronization, which pushes and pulls the dashboards on a single
+// branch. The state of the last synchronization is required to decide in which direction a dashboard has changed.
+func (options SynchronizeOptions) validateBidirectional() error {
+	push := options.PushConfiguration
+	pull := options.PullConfiguration
+
+	switch {
+	case !push.Enable || !pull.Enable:
+		return errors.New("both the push and the pull configuration must be enabled")
+	case push.GitBranch != pull.GitBranch:
+		return errors.New("the push and the pull configuration must use the same branch")
+	case options.SyncState.Storage == "":
+		return errors.New("the synchronization state must be stored")
+	case !push.PushTags:
+		return errors.New("the sync tag must be kept during exporting, otherwise exported dashboards are not selected again")
+	case push.PullRequest.Enable:
+		return errors.New("pull requests are not supported")
+	case pull.GitRevision != "" || pull.GitTagRange != "":
+		return errors.New("the pull configuration must not be pinned to a revision")
+	}
+	return nil
+}
+
+// Returns the Grafana version of the given dashboard after its last synchronization in either direction.
+func (s *Synchronization) lastSyncedVersion(uid string) (uint, bool) {
+	pushState, pushed := s.state.Push[uid]
+	pullState, pulled := s.state.Pull[uid]
+	if pushed && (!pulled || pushState.GrafanaVersion > pullState.GrafanaVersion) {
+		return pushState.GrafanaVersion, true
+	}
+	return pullState.GrafanaVersion, pulled
+}
+
+// Checks whether the given dashboard in Git has been exported by this job and has not been changed in Grafana since,
+// so importing it again would only create a new version, which would be exported again.
+func (s *Synchronization) isOwnExport(dashboard DashboardWithCustomFields, grafanaVersion uint) bool {
+	if dashboard.SyncOrigin != s.options.JobName {
+		return false
+	}
+	pushState, ok := s.state.Push[dashboard.UID]
+	return ok && pushState.GrafanaVersion == grafanaVersion
+}
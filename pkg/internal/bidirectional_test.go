@@ -0,0 +1,170 @@
+package internal
+
+import (
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// an instance of a bidirectional synchronization with its own Grafana and state
+type bidirectionalInstance struct {
+	grafana *fakeGrafana
+	options SynchronizeOptions
+}
+
+// creates an instance synchronizing the dashboards of a new fake Grafana bidirectionally with the branch "main"
+func newBidirectionalInstance(t *testing.T, jobName string, remote testRemote, conflictPolicy string) bidirectionalInstance {
+	grafana := startFakeGrafana(t)
+	return bidirectionalInstance{
+		grafana: grafana,
+		options: SynchronizeOptions{
+			JobName:          jobName,
+			GrafanaToken:     "token",
+			GrafanaUrl:       grafana.url,
+			GitRepositoryUrl: remote.url,
+			PrivateKeyFile:   remote.keyFile,
+			SyncState:        StateConfiguration{Storage: "local", Path: filepath.Join(t.TempDir(), "state.json")},
+			Bidirectional:    true,
+			PushConfiguration: PushConfiguration{
+				SyncConfiguration: SyncConfiguration{Enable: true, GitBranch: "main"},
+				TagPattern:        "sync",
+				PushTags:          true,
+			},
+			PullConfiguration: PullConfiguration{
+				SyncConfiguration: SyncConfiguration{Enable: true, GitBranch: "main"},
+				ConflictPolicy:    conflictPolicy,
+			},
+		},
+	}
+}
+
+// creates two instances sharing the dashboard "d1", which has been created in the first instance and synchronized
+// into the second one
+func newSynchronizedInstances(t *testing.T, conflictPolicy string) (bidirectionalInstance, bidirectionalInstance, testRemote) {
+	remote := newTestRemote(t)
+	remote.commitFiles(t, "main", map[string]string{"README.md": "Shared dashboards"})
+
+	a := newBidirectionalInstance(t, "a", remote, conflictPolicy)
+	b := newBidirectionalInstance(t, "b", remote, conflictPolicy)
+	a.grafana.addDashboard("Team", "d1", "D1", "sync")
+	synchronize(t, a.options)
+	synchronize(t, b.options)
+
+	if b.grafana.dashboard("d1") == nil {
+		t.Fatal("expected the dashboard to be imported into the second instance")
+	}
+	return a, b, remote
+}
+
+// helper function to return the sorted paths of the dashboard files of the given branch
+func dashboardFilePaths(t *testing.T, remote testRemote, branch string) []string {
+	var paths []string
+	for filePath := range remote.files(t, branch) {
+		if strings.HasSuffix(filePath, ".json") {
+			paths = append(paths, filePath)
+		}
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+func TestBidirectionalSkipsOwnExports(t *testing.T) {
+	a, b, remote := newSynchronizedInstances(t, "")
+
+	for run := 0; run < 2; run++ {
+		synchronize(t, a.options)
+		synchronize(t, b.options)
+	}
+
+	if saves := a.grafana.saveCount("d1"); saves != 1 {
+		t.Errorf("expected the dashboard not to be imported back into its origin, got %d saves", saves)
+	}
+	if saves := b.grafana.saveCount("d1"); saves != 1 {
+		t.Errorf("expected the dashboard to be imported once, got %d saves", saves)
+	}
+	if paths := dashboardFilePaths(t, remote, "main"); len(paths) != 1 || paths[0] != "Team/D1.json" {
+		t.Errorf("expected only the file of the dashboard, got %v", paths)
+	}
+	if file := remote.files(t, "main")["Team/D1.json"]; !strings.Contains(file, `"syncOrigin": "a"`) {
+		t.Errorf("expected the dashboard to be exported by its origin only, got %s", file)
+	}
+}
+
+func TestBidirectionalRemovesFileOfRenamedDashboard(t *testing.T) {
+	a, b, remote := newSynchronizedInstances(t, "")
+
+	b.grafana.editDashboard("d1", "title", "D1 from B")
+	for run := 0; run < 3; run++ {
+		synchronize(t, b.options)
+		synchronize(t, a.options)
+	}
+
+	if paths := dashboardFilePaths(t, remote, "main"); len(paths) != 1 || paths[0] != "Team/D1 from B.json" {
+		t.Errorf("expected only the file of the renamed dashboard, got %v", paths)
+	}
+	if title := a.grafana.dashboard("d1").model["title"]; title != "D1 from B" {
+		t.Errorf("expected the renamed dashboard to be imported, got %v", title)
+	}
+	// the first instance imports the renamed dashboard once, the second instance does not import its own change
+	if saves := a.grafana.saveCount("d1"); saves != 2 {
+		t.Errorf("expected a single import of the renamed dashboard, got %d saves", saves)
+	}
+	if saves := b.grafana.saveCount("d1"); saves != 2 {
+		t.Errorf("expected the renamed dashboard not to be imported again, got %d saves", saves)
+	}
+}
+
+func TestBidirectionalSkipsFilesSharingUID(t *testing.T) {
+	a, _, remote := newSynchronizedInstances(t, "")
+	remote.commitFiles(t, "main", map[string]string{
+		"Team/D1 from B.json": `{"uid": "d1", "title": "D1 from B", "tags": ["sync"], "syncOrigin": "b"}`,
+	})
+
+	for run := 0; run < 2; run++ {
+		synchronize(t, a.options)
+	}
+
+	if saves := a.grafana.saveCount("d1"); saves != 1 {
+		t.Errorf("expected none of the files sharing the UID to be imported, got %d saves", saves)
+	}
+}
+
+func TestBidirectionalConflicts(t *testing.T) {
+	tests := []struct {
+		policy string
+		// the timezone of the dashboard in the second instance and in Git after the synchronization
+		grafanaTimezone string
+		gitTimezone     string
+		fails           bool
+	}{
+		{policy: conflictPolicyGitWins, grafanaTimezone: "utc", gitTimezone: "utc"},
+		{policy: conflictPolicyGrafanaWins, grafanaTimezone: "browser", gitTimezone: "browser"},
+		{policy: conflictPolicySkipAndReport, grafanaTimezone: "browser", gitTimezone: "utc"},
+		{policy: conflictPolicyFail, grafanaTimezone: "browser", gitTimezone: "utc", fails: true},
+	}
+	for _, test := range tests {
+		t.Run(test.policy, func(t *testing.T) {
+			a, b, remote := newSynchronizedInstances(t, test.policy)
+			a.grafana.editDashboard("d1", "timezone", "utc")
+			b.grafana.editDashboard("d1", "timezone", "browser")
+			synchronize(t, a.options)
+			savesBefore := b.grafana.saveCount("d1")
+
+			err := NewSynchronizer(b.options).Synchronize(false)
+			if test.fails != (err != nil) {
+				t.Fatalf("expected the synchronization to fail: %v, got %v", test.fails, err)
+			}
+
+			if timezone := b.grafana.dashboard("d1").model["timezone"]; timezone != test.grafanaTimezone {
+				t.Errorf("expected timezone %q in Grafana, got %v", test.grafanaTimezone, timezone)
+			}
+			if test.grafanaTimezone == "browser" && b.grafana.saveCount("d1") != savesBefore {
+				t.Errorf("expected the dashboard changed in Grafana not to be overwritten")
+			}
+			if file := remote.files(t, "main")["Team/D1.json"]; !strings.Contains(file, `"timezone": "`+test.gitTimezone+`"`) {
+				t.Errorf("expected timezone %q in Git, got %s", test.gitTimezone, file)
+			}
+		})
+	}
+}
@@ -0,0 +1,212 @@
+package internal
+
+import (
+	"bytes"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+)
+
+// the author name used in case no author is configured
+const defaultCommitAuthor = "grafana-dashboard-sync-plugin"
+
+// the commit message template used in case no template is configured
+const defaultCommitMessage = "Synchronized Dashboards with tag <{{.TagPattern}}>"
+
+type CommitConfiguration struct {
+	AuthorName     string `yaml:"author-name"`
+	AuthorEmail    string `yaml:"author-email"`
+	CommitterName  string `yaml:"committer-name"`
+	CommitterEmail string `yaml:"committer-email"`
+	// template of the commit message, see CommitMessageData for the available placeholders
+	Message string `yaml:"message"`
+	// creates one commit per changed dashboard ("dashboard") or per editor ("editor") attributed to the Grafana
+	// user who edited the dashboards, instead of a single commit (empty)
+	GroupBy string `yaml:"group-by"`
+	// key used to sign the commits
+	Signing SigningConfiguration `yaml:"signing"`
+}
+
+// ExportFile is a dashboard file to add to the repository
+type ExportFile struct {
+	// the path of the dashboard the file belongs to, which is the directory of an exploded dashboard
+	Dashboard string
+	// the content of the file, nil in case the file is removed
+	Content []byte
+	// the Grafana user who updated the dashboard most recently
+	UpdatedBy string
+	// the time the dashboard was updated most recently
+	Updated time.Time
+}
+
+// a group of files which are committed together
+type exportFileGroup struct {
+	Paths     []string
+	UpdatedBy string
+	Updated   time.Time
+}
+
+// CommitMessageData contains the values which can be used in the commit message template, e.g. "{{.JobName}}"
+type CommitMessageData struct {
+	JobName    string
+	GrafanaUrl string
+	TagPattern string
+	// the Grafana user the commit is attributed to, in case commits are grouped by dashboard or editor
+	Editor string
+	// the paths of the dashboards changed by the commit
+	Dashboards []string
+	// the number of dashboards changed by the commit
+	ChangedCount int
+	// the number of exported dashboards, including unchanged ones
+	TotalCount int
+}
+
+// Parses the configured commit message template or the default template if none is configured.
+func (configuration CommitConfiguration) messageTemplate() (*template.Template, error) {
+	if configuration.GroupBy != "" && configuration.GroupBy != "dashboard" && configuration.GroupBy != "editor" {
+		return nil, fmt.Errorf("invalid commit grouping %q, must be 'dashboard' or 'editor'", configuration.GroupBy)
+	}
+
+	message := configuration.Message
+	if message == "" {
+		message = defaultCommitMessage
+	}
+	return template.New("commit-message").Parse(message)
+}
+
+// Returns the author signature to use for commits created at the given time.
+func (configuration CommitConfiguration) author(when time.Time) object.Signature {
+	name := configuration.AuthorName
+	if name == "" {
+		name = defaultCommitAuthor
+	}
+	return object.Signature{
+		Name:  name,
+		Email: configuration.AuthorEmail,
+		When:  when,
+	}
+}
+
+// Returns the committer signature to use for commits created at the given time. Defaults to the author.
+func (configuration CommitConfiguration) committer(when time.Time) object.Signature {
+	if configuration.CommitterName == "" && configuration.CommitterEmail == "" {
+		return configuration.author(when)
+	}
+	return object.Signature{
+		Name:  configuration.CommitterName,
+		Email: configuration.CommitterEmail,
+		When:  when,
+	}
+}
+
+// Groups the given files according to the given grouping. Groups are ordered by their most recent update,
+// so the resulting commits reflect the order in which the dashboards have been edited.
+func groupExportFiles(files map[string]ExportFile, groupBy string) []exportFileGroup {
+	paths := make([]string, 0, len(files))
+	for path := range files {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	if groupBy == "" {
+		return []exportFileGroup{{Paths: paths}}
+	}
+
+	var groups []exportFileGroup
+	groupIndices := make(map[string]int)
+	for _, path := range paths {
+		file := files[path]
+
+		key := file.UpdatedBy
+		if groupBy == "dashboard" {
+			key = file.Dashboard
+		}
+		index, ok := groupIndices[key]
+		if !ok {
+			groups = append(groups, exportFileGroup{UpdatedBy: file.UpdatedBy})
+			index = len(groups) - 1
+			groupIndices[key] = index
+		}
+
+		groups[index].Paths = append(groups[index].Paths, path)
+		if file.Updated.After(groups[index].Updated) {
+			groups[index].Updated = file.Updated
+		}
+	}
+
+	sort.SliceStable(groups, func(i, j int) bool {
+		return groups[i].Updated.Before(groups[j].Updated)
+	})
+	return groups
+}
+
+// Returns the sorted paths of the dashboards the files with the given paths belong to.
+func exportedDashboards(files map[string]ExportFile, paths []string) []string {
+	dashboards := make(map[string]bool)
+	for _, path := range paths {
+		if file, ok := files[path]; ok && file.Dashboard != "" {
+			dashboards[file.Dashboard] = true
+		} else {
+			dashboards[path] = true
+		}
+	}
+
+	result := make([]string, 0, len(dashboards))
+	for dashboard := range dashboards {
+		result = append(result, dashboard)
+	}
+	sort.Strings(result)
+	return result
+}
+
+// Returns the paths of the dashboards the given files of the repository belong to, which are the files themselves or the
+// directories of exploded dashboards. Files which do not belong to a dashboard, like folder metadata, are ignored.
+func dashboardsOfFiles(paths []string) []string {
+	dashboards := make(map[string]bool)
+	for _, filePath := range paths {
+		if dashboard := dashboardOfFile(filePath); dashboard != "" && !isFolderMetadataFile(path.Base(dashboard)) {
+			dashboards[dashboard] = true
+		}
+	}
+
+	result := make([]string, 0, len(dashboards))
+	for dashboard := range dashboards {
+		result = append(result, dashboard)
+	}
+	sort.Strings(result)
+	return result
+}
+
+// Returns the path of the dashboard the given file of the repository belongs to, which is either the file itself or the
+// directory of an exploded dashboard. Returns an empty path for files which are not located in a folder.
+func dashboardOfFile(filePath string) string {
+	segments := strings.SplitN(filePath, "/", 3)
+	if len(segments) < 2 {
+		return ""
+	}
+	return segments[0] + "/" + segments[1]
+}
+
+// Returns the number of dashboards the given files belong to.
+func countExportedDashboards(files map[string]ExportFile) int {
+	paths := make([]string, 0, len(files))
+	for path := range files {
+		paths = append(paths, path)
+	}
+	return len(exportedDashboards(files, paths))
+}
+
+// Renders the given commit message template using the given data.
+func renderCommitMessage(messageTemplate *template.Template, data CommitMessageData) (string, error) {
+	var message bytes.Buffer
+	err := messageTemplate.Execute(&message, data)
+	if err != nil {
+		return "", err
+	}
+	return message.String(), nil
+}
@@ -0,0 +1,110 @@
+package internal
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	sdk "github.com/NovatecConsulting/grafana-api-go-sdk"
+)
+
+// policies how conflicting changes of a dashboard in Git and in Grafana are resolved
+const (
+	conflictPolicyGitWins       = "git-wins"
+	conflictPolicyGrafanaWins   = "grafana-wins"
+	conflictPolicySkipAndReport = "skip-and-report"
+	conflictPolicyFail          = "fail"
+)
+
+// the prefix of the version messages of dashboards imported from Git
+const syncVersionMessagePrefix = "[SYNC]"
+
+// the number of recent dashboard versions searched for the last import from Git
+const conflictVersionLimit = 100
+
+// matches the hash of the imported content in the version messages of imported dashboards
+var syncContentHashPattern = regexp.MustCompile(`content ([0-9a-f]{16})\)`)
+
+// Returns the configured conflict policy or the default policy if none is configured.
+func conflictPolicy(policy string) (string, error) {
+	switch policy {
+	case "":
+		return conflictPolicyGitWins, nil
+	case conflictPolicyGitWins, conflictPolicyGrafanaWins, conflictPolicySkipAndReport, conflictPolicyFail:
+		return policy, nil
+	default:
+		return "", fmt.Errorf("invalid conflict policy %q, must be '%s', '%s', '%s' or '%s'", policy,
+			conflictPolicyGitWins, conflictPolicyGrafanaWins, conflictPolicySkipAndReport, conflictPolicyFail)
+	}
+}
+
+// helper function to return the hash of the given normalized dashboard, which is recorded in the version message on import
+func contentHash(content []byte) string {
+	hash := sha256.Sum256(content)
+	return hex.EncodeToString(hash[:])[:16]
+}
+
+// Checks whether the given dashboard has been changed in Git and in Grafana since it has been imported most recently.
+// The last import is determined by the stored synchronization state or, if there is none, by the version history of the
+// dashboard: Grafana has changed if a newer version exists and Git has changed if the content hash recorded in the version
+// message differs from the given hash of the dashboard in Git. Dashboards which have never been imported are considered
+// to be changed in Git only, as there is no common base.
+func (s *Synchronization) detectChanges(grafanaDashboard sdk.Board, gitContentHash string) (gitChanged bool, grafanaChanged bool, err error) {
+	if grafanaDashboard.ID == 0 {
+		return true, false, nil
+	}
+
+	// the state of the last synchronization is preferred, as it does not require to request the version history
+	if s.state != nil {
+		if dashboardState, ok := s.state.Pull[grafanaDashboard.UID]; ok {
+			return dashboardState.ContentHash != gitContentHash, dashboardState.GrafanaVersion != grafanaDashboard.Version, nil
+		}
+	}
+
+	versions, err := s.grafanaApi.GetDashboardVersions(int(grafanaDashboard.ID), conflictVersionLimit)
+	if err != nil {
+		return false, false, err
+	}
+
+	for _, version := range versions {
+		if !strings.HasPrefix(version.Message, syncVersionMessagePrefix) {
+			continue
+		}
+
+		// imports without recorded hash are assumed to differ, as it cannot be determined whether Git has changed
+		match := syncContentHashPattern.FindStringSubmatch(version.Message)
+		gitChanged = match == nil || match[1] != gitContentHash
+		grafanaChanged = uint(version.Version) < grafanaDashboard.Version
+		return gitChanged, grafanaChanged, nil
+	}
+	return true, false, nil
+}
+
+// Returns the paths of the dashboard files of the given folders, which contain the UID of another file, mapped by the UID.
+// Such files, e.g. the previous file of a renamed dashboard, would overwrite each other on every import.
+func duplicateDashboardFiles(fileMap map[string]map[string][]byte) map[string][]string {
+	files := make(map[string][]string)
+	for folderName, dashboardFiles := range fileMap {
+		for fileName, dashboardJson := range dashboardFiles {
+			var dashboard struct {
+				UID string `json:"uid"`
+			}
+			if json.Unmarshal(dashboardJson, &dashboard) == nil && dashboard.UID != "" {
+				files[dashboard.UID] = append(files[dashboard.UID], folderName+"/"+strings.TrimSuffix(fileName, "/"))
+			}
+		}
+	}
+
+	for uid, paths := range files {
+		if len(paths) < 2 {
+			delete(files, uid)
+		} else {
+			sort.Strings(paths)
+		}
+	}
+	return files
+}
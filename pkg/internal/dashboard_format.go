@@ -0,0 +1,275 @@
+package internal
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// the indentation of exported dashboards, used in case no indentation is configured
+const defaultJsonIndent = 2
+
+// the fields of a dashboard which are specific to a Grafana instance, used in case no volatile fields are configured
+var defaultVolatileFields = []string{"id", "version", "iteration"}
+
+// the extensions of dashboard files which are imported, Jsonnet libraries (".libsonnet") are only imported by other files
+var dashboardFileExtensions = []string{".json", ".yaml", ".yml", ".jsonnet"}
+
+// helper function to return the extension of dashboard files stored in the given format
+func dashboardFileExtension(format string) (string, error) {
+	switch format {
+	case "", "json":
+		return ".json", nil
+	case "yaml":
+		return ".yaml", nil
+	default:
+		return "", fmt.Errorf("invalid file format %q, must be 'json' or 'yaml'", format)
+	}
+}
+
+// helper function to check whether files with the given extension contain dashboards
+func isDashboardFileExtension(extension string) bool {
+	for _, dashboardExtension := range dashboardFileExtensions {
+		if extension == dashboardExtension {
+			return true
+		}
+	}
+	return false
+}
+
+// CanonicalizeJson formats the given JSON document in a stable way, so it can be compared line by line: the given
+// volatile fields are removed, object keys are sorted, the given number of spaces is used for indentation and numbers
+// are written in their shortest representation. Nested fields are addressed by their dot separated path, e.g. "panels.id".
+func CanonicalizeJson(document []byte, volatileFields []string, indent int) ([]byte, error) {
+	if indent <= 0 {
+		indent = defaultJsonIndent
+	}
+
+	var value interface{}
+	decoder := json.NewDecoder(bytes.NewReader(document))
+	decoder.UseNumber()
+	err := decoder.Decode(&value)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, field := range volatileFields {
+		removeField(value, strings.Split(field, "."))
+	}
+	value = canonicalizeNumbers(value)
+
+	// maps are encoded with sorted keys
+	var result bytes.Buffer
+	encoder := json.NewEncoder(&result)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", strings.Repeat(" ", indent))
+	err = encoder.Encode(value)
+	if err != nil {
+		return nil, err
+	}
+	return result.Bytes(), nil
+}
+
+// helper function to remove the field with the given path from the given decoded JSON value. Arrays are traversed,
+// so the remaining path is removed from each of their elements.
+func removeField(value interface{}, path []string) {
+	switch typed := value.(type) {
+	case map[string]interface{}:
+		if len(path) == 1 {
+			delete(typed, path[0])
+		} else if element, ok := typed[path[0]]; ok {
+			removeField(element, path[1:])
+		}
+	case []interface{}:
+		for _, element := range typed {
+			removeField(element, path)
+		}
+	}
+}
+
+// helper function to replace the numbers of the given decoded JSON value by their shortest representation,
+// e.g. "1.50" by "1.5" or "1e3" by "1000". Integers are kept as they are, so large integers do not lose precision.
+func canonicalizeNumbers(value interface{}) interface{} {
+	switch typed := value.(type) {
+	case map[string]interface{}:
+		for key, element := range typed {
+			typed[key] = canonicalizeNumbers(element)
+		}
+	case []interface{}:
+		for index, element := range typed {
+			typed[index] = canonicalizeNumbers(element)
+		}
+	case json.Number:
+		if !strings.ContainsAny(typed.String(), ".eE") {
+			return typed
+		}
+		if float, err := typed.Float64(); err == nil {
+			return float
+		}
+	}
+	return value
+}
+
+// JsonToYaml converts the given JSON document into YAML using the given number of spaces for indentation. Object keys
+// are sorted and numbers are written as they are, so the document can be converted back into the same JSON.
+func JsonToYaml(document []byte, indent int) ([]byte, error) {
+	if indent <= 0 {
+		indent = defaultJsonIndent
+	}
+
+	var value interface{}
+	decoder := json.NewDecoder(bytes.NewReader(document))
+	decoder.UseNumber()
+	err := decoder.Decode(&value)
+	if err != nil {
+		return nil, err
+	}
+
+	var result bytes.Buffer
+	encoder := yaml.NewEncoder(&result)
+	encoder.SetIndent(indent)
+	err = encoder.Encode(jsonToYamlNode(value))
+	if err != nil {
+		return nil, err
+	}
+	err = encoder.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	// make sure the dashboard is imported exactly as it has been exported
+	converted, err := YamlToJson(result.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	equal, err := jsonEqual(document, converted)
+	if err != nil {
+		return nil, err
+	} else if !equal {
+		return nil, errors.New("the document cannot be converted into YAML without changing it")
+	}
+	return result.Bytes(), nil
+}
+
+// helper function to check whether the given JSON documents are equal, regardless of their formatting
+func jsonEqual(first []byte, second []byte) (bool, error) {
+	firstCanonical, err := CanonicalizeJson(first, nil, defaultJsonIndent)
+	if err != nil {
+		return false, err
+	}
+	secondCanonical, err := CanonicalizeJson(second, nil, defaultJsonIndent)
+	if err != nil {
+		return false, err
+	}
+	return bytes.Equal(firstCanonical, secondCanonical), nil
+}
+
+// helper function to convert the given decoded JSON value into a YAML node
+func jsonToYamlNode(value interface{}) *yaml.Node {
+	switch typed := value.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(typed))
+		for key := range typed {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		node := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+		for _, key := range keys {
+			node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key}, jsonToYamlNode(typed[key]))
+		}
+		return node
+	case []interface{}:
+		node := &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+		for _, element := range typed {
+			node.Content = append(node.Content, jsonToYamlNode(element))
+		}
+		return node
+	case json.Number:
+		if strings.ContainsAny(typed.String(), ".eE") {
+			return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!float", Value: typed.String()}
+		}
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!int", Value: typed.String()}
+	case string:
+		node := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: typed}
+		if strings.TrimSpace(typed) == "" && strings.Contains(typed, "\n") {
+			// literal block scalars consisting of line breaks only are not read back correctly
+			node.Style = yaml.DoubleQuotedStyle
+		}
+		return node
+	case bool:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!bool", Value: strconv.FormatBool(typed)}
+	default:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!null", Value: "null"}
+	}
+}
+
+// YamlToJson converts the given YAML document into JSON. Numbers are kept as they are written, so documents
+// created by JsonToYaml are converted back into the same JSON.
+func YamlToJson(document []byte) ([]byte, error) {
+	var node yaml.Node
+	err := yaml.Unmarshal(document, &node)
+	if err != nil {
+		return nil, err
+	}
+
+	value, err := yamlNodeToJson(&node)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(value)
+}
+
+// helper function to convert the given YAML node into a value which can be encoded as JSON
+func yamlNodeToJson(node *yaml.Node) (interface{}, error) {
+	switch node.Kind {
+	case yaml.DocumentNode:
+		if len(node.Content) == 0 {
+			return nil, nil
+		}
+		return yamlNodeToJson(node.Content[0])
+	case yaml.AliasNode:
+		return yamlNodeToJson(node.Alias)
+	case yaml.MappingNode:
+		result := make(map[string]interface{}, len(node.Content)/2)
+		for index := 0; index+1 < len(node.Content); index += 2 {
+			value, err := yamlNodeToJson(node.Content[index+1])
+			if err != nil {
+				return nil, err
+			}
+			result[node.Content[index].Value] = value
+		}
+		return result, nil
+	case yaml.SequenceNode:
+		result := make([]interface{}, 0, len(node.Content))
+		for _, element := range node.Content {
+			value, err := yamlNodeToJson(element)
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, value)
+		}
+		return result, nil
+	}
+
+	switch node.ShortTag() {
+	case "!!str":
+		return node.Value, nil
+	case "!!int", "!!float":
+		// numbers written in a YAML specific notation, e.g. "0x1F", are decoded by the YAML library
+		if json.Valid([]byte(node.Value)) {
+			return json.Number(node.Value), nil
+		}
+	case "!!null":
+		return nil, nil
+	}
+	var value interface{}
+	err := node.Decode(&value)
+	return value, err
+}
@@ -0,0 +1,120 @@
+package internal
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// the names of the files and directories of exploded dashboards, which are stored as a directory
+const explodedMetadataFile = "dashboard"
+const explodedTemplatingFile = "templating"
+const explodedPanelsDirectory = "panels"
+const explodedRowsDirectory = "rows"
+
+// ExplodeDashboard splits the given dashboard JSON into a metadata document, one document per panel or row and a
+// templating document, so changes of different panels do not conflict. The documents are keyed by their path relative
+// to the dashboard directory without extension. The numbering of the panel and row files keeps their order.
+func ExplodeDashboard(document []byte) (map[string][]byte, error) {
+	var dashboard map[string]json.RawMessage
+	err := json.Unmarshal(document, &dashboard)
+	if err != nil {
+		return nil, err
+	}
+
+	parts := make(map[string][]byte)
+	for _, directory := range []string{explodedPanelsDirectory, explodedRowsDirectory} {
+		var elements []json.RawMessage
+		if content, ok := dashboard[directory]; ok {
+			// empty arrays are kept in the metadata file, so they are restored as they are
+			if json.Unmarshal(content, &elements) != nil || len(elements) == 0 {
+				continue
+			}
+		}
+
+		digits := len(strconv.Itoa(len(elements)))
+		if digits < 3 {
+			digits = 3
+		}
+		for index, element := range elements {
+			parts[directory+"/"+explodedElementName(index, digits, element)] = element
+		}
+		delete(dashboard, directory)
+	}
+	if templating, ok := dashboard[explodedTemplatingFile]; ok {
+		parts[explodedTemplatingFile] = templating
+		delete(dashboard, explodedTemplatingFile)
+	}
+
+	metadata, err := json.Marshal(dashboard)
+	if err != nil {
+		return nil, err
+	}
+	parts[explodedMetadataFile] = metadata
+	return parts, nil
+}
+
+// helper function to return the file name of the panel or row with the given index, consisting of its number and title
+func explodedElementName(index int, digits int, element json.RawMessage) string {
+	name := fmt.Sprintf("%0*d", digits, index+1)
+
+	var titled struct {
+		Title string `json:"title"`
+	}
+	if json.Unmarshal(element, &titled) == nil {
+		slug := regexp.MustCompile(`[^a-z0-9]+`).ReplaceAllString(strings.ToLower(titled.Title), "-")
+		if len(slug) > 40 {
+			slug = slug[:40]
+		}
+		slug = strings.Trim(slug, "-")
+		if slug != "" {
+			name += "-" + slug
+		}
+	}
+	return name
+}
+
+// AssembleDashboard reassembles a dashboard from the documents created by ExplodeDashboard, which are keyed by their
+// path relative to the dashboard directory without extension.
+func AssembleDashboard(parts map[string][]byte) ([]byte, error) {
+	metadata, ok := parts[explodedMetadataFile]
+	if !ok {
+		return nil, errors.New("the metadata file of the dashboard is missing")
+	}
+	var dashboard map[string]json.RawMessage
+	err := json.Unmarshal(metadata, &dashboard)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, directory := range []string{explodedPanelsDirectory, explodedRowsDirectory} {
+		var names []string
+		for name := range parts {
+			if strings.HasPrefix(name, directory+"/") {
+				names = append(names, name)
+			}
+		}
+		if len(names) == 0 {
+			continue
+		}
+		sort.Strings(names)
+
+		elements := make([]json.RawMessage, 0, len(names))
+		for _, name := range names {
+			elements = append(elements, parts[name])
+		}
+		dashboard[directory], err = json.Marshal(elements)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if templating, ok := parts[explodedTemplatingFile]; ok {
+		dashboard[explodedTemplatingFile] = templating
+	}
+
+	return json.Marshal(dashboard)
+}
@@ -3,33 +3,63 @@ package internal
 import (
 	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
 	"time"
 
 	"gopkg.in/src-d/go-git.v4/plumbing"
 
-	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/Masterminds/semver/v3"
 	log "github.com/sirupsen/logrus"
 	ssh2 "golang.org/x/crypto/ssh"
 	"gopkg.in/src-d/go-billy.v4"
 	"gopkg.in/src-d/go-billy.v4/memfs"
+	"gopkg.in/src-d/go-billy.v4/osfs"
 	"gopkg.in/src-d/go-git.v4"
-	object2 "gopkg.in/src-d/go-git.v4/plumbing/object"
+	"gopkg.in/src-d/go-git.v4/config"
+	"gopkg.in/src-d/go-git.v4/plumbing/cache"
+	"gopkg.in/src-d/go-git.v4/plumbing/filemode"
+	"gopkg.in/src-d/go-git.v4/plumbing/format/index"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+	"gopkg.in/src-d/go-git.v4/plumbing/transport"
 	"gopkg.in/src-d/go-git.v4/plumbing/transport/ssh"
+	"gopkg.in/src-d/go-git.v4/storage"
+	"gopkg.in/src-d/go-git.v4/storage/filesystem"
 	"gopkg.in/src-d/go-git.v4/storage/memory"
 )
 
+// maximum time to wait for another process releasing the lock of a cached working copy
+const cacheLockTimeout = 10 * time.Minute
+
+// ErrPushRejected is returned in case a push was rejected because the remote branch has been updated
+var ErrPushRejected = errors.New("push rejected because the remote branch has been updated")
+
+// pattern matching the full hash of a commit
+var commitHashPattern = regexp.MustCompile(`^[0-9a-f]{40}$`)
+
 // GitApi access to git api
 type GitApi struct {
-	gitUrl             string
-	authenticator      *ssh.PublicKeys
-	inMemoryStore      memory.Storage
-	inMemoryFileSystem billy.Filesystem
-	repository         *git.Repository
+	gitUrl        string
+	authenticator *ssh.PublicKeys
+	store         storage.Storer
+	fileSystem    billy.Filesystem
+	repository    *git.Repository
+	// directory of the on-disk working copy, empty in case the repository is kept in memory
+	cacheDirectory string
+	cacheLock      *FileLock
+	// whether only the tip of the branches is fetched, which is sufficient as long as nothing is pushed
+	shallow bool
 }
 
-// NewGitApi creates a new NewGitApi instance
-func NewGitApi(gitUrl string, privateKeyFile string) *GitApi {
+// NewGitApi creates a new NewGitApi instance. In case a cache directory is given, the repository
+// is kept as working copy in a sub-directory of it and reused across runs, otherwise it is cloned into memory.
+// Only the requested branches are fetched, which is done without history in case shallow is set.
+func NewGitApi(gitUrl string, privateKeyFile string, cacheDirectory string, shallow bool) *GitApi {
 	authenticator, err := createPublicKeys(privateKeyFile)
 	if err != nil {
 		log.WithFields(log.Fields{
@@ -37,8 +67,16 @@ func NewGitApi(gitUrl string, privateKeyFile string) *GitApi {
 			"private-key-file": privateKeyFile,
 		}).Fatal("Failed to load publiy key from the private key.")
 	}
-	inMemoryStore, inMemoryFileSystem := createInMemory()
-	gitApi := GitApi{gitUrl, authenticator, *inMemoryStore, inMemoryFileSystem, nil}
+
+	gitApi := GitApi{gitUrl: gitUrl, authenticator: authenticator, shallow: shallow}
+	if cacheDirectory == "" {
+		store, fileSystem := createInMemory()
+		gitApi.store = store
+		gitApi.fileSystem = fileSystem
+	} else {
+		gitApi.cacheDirectory = filepath.Join(cacheDirectory, repositoryDirectoryName(gitUrl))
+		gitApi.store, gitApi.fileSystem = createOnDisk(gitApi.cacheDirectory)
+	}
 
 	return &gitApi
 }
@@ -61,7 +99,7 @@ func createPublicKeys(privateKeyFile string) (*ssh.PublicKeys, error) {
 }
 
 // helper function to create the in memory storage and filesystem
-func createInMemory() (*memory.Storage, billy.Filesystem) {
+func createInMemory() (storage.Storer, billy.Filesystem) {
 	// prepare in memory
 	store := memory.NewStorage()
 	var fs billy.Filesystem
@@ -70,57 +108,452 @@ func createInMemory() (*memory.Storage, billy.Filesystem) {
 	return store, fs
 }
 
+// helper function to create the storage and filesystem of an on-disk working copy
+func createOnDisk(directory string) (storage.Storer, billy.Filesystem) {
+	fs := osfs.New(directory)
+	dot, _ := fs.Chroot(git.GitDirName)
+	store := filesystem.NewStorage(dot, cache.NewObjectLRUDefault())
+
+	return store, fs
+}
+
+// helper function to derive a directory name for the working copy of the given repository
+func repositoryDirectoryName(gitUrl string) string {
+	name := regexp.MustCompile(`[^a-zA-Z0-9._-]+`).ReplaceAllString(gitUrl, "_")
+	return strings.Trim(name, "_.")
+}
+
 // CloneRepo clones the gitApi.gitUrls repository
 func (gitApi *GitApi) CloneRepo(branchName string) (*git.Repository, error) {
-	//todo: refactor this function
-
 	if gitApi.repository != nil {
-		// only checkout branch if repository has already be cloned
+		// only fetch and checkout branch if repository has already be cloned
 		log.WithFields(log.Fields{
 			"repository-url": gitApi.gitUrl,
 			"branch":         branchName,
 		}).Debug("Checkout branch because repository already exists..")
 
-		repo := gitApi.repository
+		err := gitApi.fetchBranch(branchName)
+		if err != nil {
+			return nil, err
+		}
+		err = gitApi.checkoutBranch(branchName)
+		if err != nil {
+			return nil, err
+		}
 
-		worktree, _ := repo.Worktree()
+		return gitApi.repository, nil
+	}
 
-		err := worktree.Checkout(&git.CheckoutOptions{
-			Branch: plumbing.ReferenceName(fmt.Sprintf("refs/remotes/origin/%s", branchName)),
-			Force:  true,
-		})
+	if gitApi.cacheDirectory != "" {
+		return gitApi.openCachedRepo(branchName)
+	}
+
+	return gitApi.cloneRepo(branchName)
+}
+
+// clones the repository into the storage and filesystem of this instance, which are in memory by default
+func (gitApi *GitApi) cloneRepo(branchName string) (*git.Repository, error) {
+	log.WithFields(log.Fields{
+		"repository-url": gitApi.gitUrl,
+		"branch":         branchName,
+		"shallow":        gitApi.shallow,
+	}).Info("Cloning repository..")
+
+	r, err := git.Clone(gitApi.store, gitApi.fileSystem, &git.CloneOptions{
+		URL:           gitApi.gitUrl,
+		Auth:          gitApi.authenticator,
+		ReferenceName: plumbing.NewBranchReferenceName(branchName),
+		SingleBranch:  true,
+		Depth:         gitApi.depth(),
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	gitApi.repository = r
+	return r, nil
+}
+
+// locks the on-disk working copy until Close is called, so concurrent processes can not corrupt it
+func (gitApi *GitApi) lockCache() error {
+	if gitApi.cacheLock != nil {
+		return nil
+	}
+
+	err := os.MkdirAll(filepath.Dir(gitApi.cacheDirectory), 0755)
+	if err != nil {
+		return err
+	}
+	lock, err := AcquireFileLock(gitApi.cacheDirectory+".lock", cacheLockTimeout)
+	if err != nil {
+		return err
+	}
+	gitApi.cacheLock = lock
+	return nil
+}
+
+// opens the on-disk working copy of the repository, fetches the latest changes and checks out the given branch.
+func (gitApi *GitApi) openCachedRepo(branchName string) (*git.Repository, error) {
+	err := gitApi.lockCache()
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := git.Open(gitApi.store, gitApi.fileSystem)
+	if err == git.ErrRepositoryNotExists {
+		// first run, so the working copy has to be cloned
+		return gitApi.cloneRepo(branchName)
+	} else if err != nil {
+		return nil, err
+	}
+
+	shallowCommits, err := gitApi.store.Shallow()
+	if err != nil {
+		return nil, err
+	}
+	if gitApi.shallow && len(shallowCommits) == 0 {
+		// fetching with depth would turn the full working copy of a previous push run into a shallow one, which then
+		// would have to be cloned again by the next push run, thus, the full history is fetched incrementally instead
+		log.WithField("directory", gitApi.cacheDirectory).Debug("Fetching full history, because the working copy is not shallow.")
+		gitApi.shallow = false
+	} else if !gitApi.shallow && len(shallowCommits) > 0 {
+		// a shallow working copy of a previous pull-only run can not be deepened, thus, it is cloned again
+		log.WithField("directory", gitApi.cacheDirectory).Info("Replacing shallow working copy, because the full history is required.")
+		err = os.RemoveAll(gitApi.cacheDirectory)
 		if err != nil {
 			return nil, err
 		}
+		gitApi.store, gitApi.fileSystem = createOnDisk(gitApi.cacheDirectory)
+		return gitApi.cloneRepo(branchName)
+	}
+	gitApi.repository = r
 
-		return gitApi.repository, nil
-	} else {
-		// clone repository into memory
-		log.WithFields(log.Fields{
-			"repository-url": gitApi.gitUrl,
-			"branch":         branchName,
-		}).Info("Cloning repository..")
+	log.WithFields(log.Fields{
+		"repository-url": gitApi.gitUrl,
+		"branch":         branchName,
+		"directory":      gitApi.cacheDirectory,
+	}).Info("Fetching repository into existing working copy..")
 
-		r, err := git.Clone(&gitApi.inMemoryStore, gitApi.inMemoryFileSystem, &git.CloneOptions{
-			URL:           gitApi.gitUrl,
-			Auth:          gitApi.authenticator,
-			ReferenceName: plumbing.NewBranchReferenceName(branchName),
-			SingleBranch:  false,
-		})
+	err = gitApi.fetchBranch(branchName)
+	if err != nil {
+		return nil, err
+	}
+
+	err = gitApi.checkoutBranch(branchName)
+	if err != nil {
+		return nil, err
+	}
 
+	return r, nil
+}
+
+// BranchExists returns whether the given branch exists in the remote repository
+func (gitApi *GitApi) BranchExists(branchName string) (bool, error) {
+	remote := git.NewRemote(memory.NewStorage(), &config.RemoteConfig{
+		Name: "origin",
+		URLs: []string{gitApi.gitUrl},
+	})
+	refs, err := remote.List(&git.ListOptions{Auth: gitApi.authenticator})
+	if err == transport.ErrEmptyRemoteRepository {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+
+	for _, ref := range refs {
+		if ref.Name() == plumbing.NewBranchReferenceName(branchName) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// CreateBranch creates and checks out a new local branch, which will be created in the remote repository once it is pushed.
+// The branch is based on the head of the given base branch or, if no base branch is given, created as orphan branch without any files.
+func (gitApi *GitApi) CreateBranch(branchName string, baseBranch string) (*git.Repository, error) {
+	log.WithFields(log.Fields{
+		"repository-url": gitApi.gitUrl,
+		"branch":         branchName,
+		"base-branch":    baseBranch,
+	}).Info("Creating branch..")
+
+	branchRef := plumbing.NewBranchReferenceName(branchName)
+
+	if baseBranch != "" {
+		_, err := gitApi.CloneRepo(baseBranch)
 		if err != nil {
 			return nil, err
 		}
 
-		gitApi.repository = r
-		return r, nil
+		head, err := gitApi.repository.Head()
+		if err != nil {
+			return nil, err
+		}
+		err = gitApi.repository.Storer.SetReference(plumbing.NewHashReference(branchRef, head.Hash()))
+		if err != nil {
+			return nil, err
+		}
+
+		worktree, err := gitApi.repository.Worktree()
+		if err != nil {
+			return nil, err
+		}
+		err = worktree.Checkout(&git.CheckoutOptions{Branch: branchRef})
+		if err != nil {
+			return nil, err
+		}
+
+		return gitApi.repository, nil
 	}
+
+	// the worktree of a repository, which has already been cloned or which is opened from the on-disk working copy, may
+	// contain the files of a previously checked out branch
+	emptyRepository := false
+	if gitApi.repository == nil {
+		var err error
+		_, emptyRepository, err = gitApi.initRepo()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// point HEAD to the unborn branch, so the next commit will be a root commit
+	err := gitApi.repository.Storer.RemoveReference(branchRef)
+	if err != nil {
+		return nil, err
+	}
+	err = gitApi.repository.Storer.SetReference(plumbing.NewSymbolicReference(plumbing.HEAD, branchRef))
+	if err != nil {
+		return nil, err
+	}
+
+	if emptyRepository {
+		return gitApi.repository, nil
+	}
+
+	// empty the index, so all files of previously checked out branches become untracked and are removed
+	err = gitApi.repository.Storer.SetIndex(&index.Index{Version: 2})
+	if err != nil {
+		return nil, err
+	}
+	worktree, err := gitApi.repository.Worktree()
+	if err != nil {
+		return nil, err
+	}
+	err = worktree.Clean(&git.CleanOptions{Dir: true})
+	if err != nil {
+		return nil, err
+	}
+
+	return gitApi.repository, nil
+}
+
+// initializes an empty repository, which is connected to the remote repository. The on-disk working copy is opened instead, if it already exists.
+// Returns whether the repository has been initialized, i.e. whether it is empty.
+func (gitApi *GitApi) initRepo() (*git.Repository, bool, error) {
+	if gitApi.cacheDirectory != "" {
+		err := gitApi.lockCache()
+		if err != nil {
+			return nil, false, err
+		}
+
+		r, err := git.Open(gitApi.store, gitApi.fileSystem)
+		if err == nil {
+			gitApi.repository = r
+			return r, false, nil
+		} else if err != git.ErrRepositoryNotExists {
+			return nil, false, err
+		}
+	}
+
+	r, err := git.Init(gitApi.store, gitApi.fileSystem)
+	if err != nil {
+		return nil, false, err
+	}
+	_, err = r.CreateRemote(&config.RemoteConfig{
+		Name: "origin",
+		URLs: []string{gitApi.gitUrl},
+	})
+	if err != nil {
+		return nil, false, err
+	}
+
+	gitApi.repository = r
+	return r, true, nil
+}
+
+// fetches the given branch from the remote repository
+func (gitApi *GitApi) fetchBranch(branchName string) error {
+	refSpec := fmt.Sprintf("+%s:%s", plumbing.NewBranchReferenceName(branchName), plumbing.NewRemoteReferenceName("origin", branchName))
+	err := gitApi.repository.Fetch(&git.FetchOptions{
+		RemoteName: "origin",
+		RefSpecs:   []config.RefSpec{config.RefSpec(refSpec)},
+		Depth:      gitApi.depth(),
+		Auth:       gitApi.authenticator,
+		Force:      true,
+	})
+	if err != nil && err != git.NoErrAlreadyUpToDate {
+		return err
+	}
+	return nil
+}
+
+// RequireHistory makes sure that the full history of the branches is fetched, e.g. to check out any of their commits,
+// although only pulling would be sufficient with their latest commit. Has to be called before the repository is cloned.
+func (gitApi *GitApi) RequireHistory() {
+	gitApi.shallow = false
+}
+
+// returns the depth to use for clone and fetch operations, where 0 means the full history
+func (gitApi *GitApi) depth() int {
+	if gitApi.shallow {
+		return 1
+	}
+	return 0
+}
+
+// checks out the given branch and resets it to the state of its remote counterpart.
+// Untracked files, e.g. left over from previous runs, are removed.
+func (gitApi *GitApi) checkoutBranch(branchName string) error {
+	repo := gitApi.repository
+
+	remoteRef, err := repo.Reference(plumbing.NewRemoteReferenceName("origin", branchName), true)
+	if err != nil {
+		return err
+	}
+	localRef := plumbing.NewHashReference(plumbing.NewBranchReferenceName(branchName), remoteRef.Hash())
+	err = repo.Storer.SetReference(localRef)
+	if err != nil {
+		return err
+	}
+
+	worktree, err := repo.Worktree()
+	if err != nil {
+		return err
+	}
+	err = worktree.Checkout(&git.CheckoutOptions{
+		Branch: localRef.Name(),
+		Force:  true,
+	})
+	if err != nil {
+		return err
+	}
+
+	return worktree.Clean(&git.CleanOptions{Dir: true})
+}
+
+// fetches all tags from the remote repository
+func (gitApi *GitApi) fetchTags() error {
+	err := gitApi.repository.Fetch(&git.FetchOptions{
+		RemoteName: "origin",
+		RefSpecs:   []config.RefSpec{"+refs/tags/*:refs/tags/*"},
+		Depth:      gitApi.depth(),
+		Auth:       gitApi.authenticator,
+		Force:      true,
+	})
+	if err != nil && err != git.NoErrAlreadyUpToDate {
+		return err
+	}
+	return nil
+}
+
+// IsCommitHash returns whether the given revision is a full commit hash
+func IsCommitHash(revision string) bool {
+	return commitHashPattern.MatchString(revision)
 }
 
-// AddFileWithContent add the given filename and content to the in memory filesystem
+// FindLatestTagInRange returns the tag with the highest semantic version matching the given constraint (e.g. "~1.4" or ">= 1.2, < 2").
+// Tags which are no valid semantic version are ignored.
+func (gitApi *GitApi) FindLatestTagInRange(versionConstraint string) (string, error) {
+	constraint, err := semver.NewConstraint(versionConstraint)
+	if err != nil {
+		return "", err
+	}
+
+	err = gitApi.fetchTags()
+	if err != nil {
+		return "", err
+	}
+
+	tags, err := gitApi.repository.Tags()
+	if err != nil {
+		return "", err
+	}
+
+	var latestTag string
+	var latestVersion *semver.Version
+	err = tags.ForEach(func(ref *plumbing.Reference) error {
+		version, err := semver.NewVersion(ref.Name().Short())
+		if err != nil {
+			return nil
+		}
+		if constraint.Check(version) && (latestVersion == nil || version.GreaterThan(latestVersion)) {
+			latestTag = ref.Name().Short()
+			latestVersion = version
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	if latestVersion == nil {
+		return "", fmt.Errorf("no tag matches the version constraint %q", versionConstraint)
+	}
+	return latestTag, nil
+}
+
+// CheckoutRevision checks out the commit the given revision is pointing to. The revision can be a tag or
+// the full hash of a commit, which is part of the history of the currently fetched branches.
+func (gitApi *GitApi) CheckoutRevision(revision string) (string, error) {
+	if !IsCommitHash(revision) {
+		err := gitApi.fetchTags()
+		if err != nil {
+			return "", err
+		}
+	}
+
+	hash, err := gitApi.repository.ResolveRevision(plumbing.Revision(revision))
+	if err != nil {
+		return "", fmt.Errorf("cannot resolve revision %q: %v", revision, err)
+	}
+
+	worktree, err := gitApi.repository.Worktree()
+	if err != nil {
+		return "", err
+	}
+	err = worktree.Checkout(&git.CheckoutOptions{
+		Hash:  *hash,
+		Force: true,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	log.WithFields(log.Fields{
+		"revision": revision,
+		"commit":   hash.String(),
+	}).Info("Checked out pinned revision.")
+
+	return hash.String(), nil
+}
+
+// Close releases the lock of the on-disk working copy, if any
+func (gitApi *GitApi) Close() {
+	if gitApi.cacheLock != nil {
+		err := gitApi.cacheLock.Release()
+		if err != nil {
+			log.WithField("error", err).Warn("Failed to release lock of the working copy.")
+		}
+		gitApi.cacheLock = nil
+	}
+}
+
+// AddFileWithContent add the given filename and content to the worktree filesystem
 func (gitApi GitApi) AddFileWithContent(fileName string, fileContent string) {
-	// add file with content to in memory filesystem
-	tempFile, err := gitApi.inMemoryFileSystem.Create(fileName)
+	// add file with content to worktree filesystem
+	tempFile, err := gitApi.fileSystem.Create(fileName)
 	if err != nil {
 		log.Fatal("create file error", "error", err)
 		return
@@ -130,41 +563,303 @@ func (gitApi GitApi) AddFileWithContent(fileName string, fileContent string) {
 	}
 }
 
-// CommitWorktree commits all changes in the filesystem
-func (gitApi GitApi) CommitWorktree(repository git.Repository, tag string) {
-	// get worktree and commit
+// StageFiles adds the given files of the filesystem to the index and returns the paths of all staged changes. Files
+// which do not exist anymore are removed from the index.
+func (gitApi GitApi) StageFiles(repository git.Repository, paths []string) ([]string, error) {
 	w, err := repository.Worktree()
 	if err != nil {
-		log.Fatal("worktree error", "error", err)
-		return
+		return nil, err
+	}
+
+	// the index is updated directly, as each call of Worktree.Add computes the status of the whole worktree
+	idx, err := repository.Storer.Index()
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range paths {
+		err = stageFile(repository, w.Filesystem, idx, path)
+		if err != nil {
+			return nil, err
+		}
+	}
+	err = repository.Storer.SetIndex(idx)
+	if err != nil {
+		return nil, err
+	}
+
+	wStatus, err := w.Status()
+	if err != nil {
+		return nil, err
+	}
+	log.Debug("worktree status", "status", wStatus)
+
+	var changedFiles []string
+	for path, fileStatus := range wStatus {
+		if fileStatus.Staging != git.Unmodified && fileStatus.Staging != git.Untracked {
+			changedFiles = append(changedFiles, path)
+		}
+	}
+	sort.Strings(changedFiles)
+
+	return changedFiles, nil
+}
+
+// helper function to store the given file of the filesystem as blob and to add it to the given index. Directories are
+// added recursively and files which do not exist are removed from the index.
+func stageFile(repository git.Repository, fileSystem billy.Filesystem, idx *index.Index, filePath string) error {
+	info, err := fileSystem.Lstat(filePath)
+	if os.IsNotExist(err) {
+		_, err = idx.Remove(filePath)
+		if err == index.ErrEntryNotFound {
+			return nil
+		}
+		return err
+	} else if err != nil {
+		return err
+	}
+
+	if info.IsDir() {
+		files, err := fileSystem.ReadDir(filePath)
+		if err != nil {
+			return err
+		}
+		for _, file := range files {
+			err = stageFile(repository, fileSystem, idx, filePath+"/"+file.Name())
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	file, err := fileSystem.Open(filePath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	blob := repository.Storer.NewEncodedObject()
+	blob.SetType(plumbing.BlobObject)
+	blob.SetSize(info.Size())
+	writer, err := blob.Writer()
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(writer, file)
+	if err != nil {
+		return err
+	}
+	err = writer.Close()
+	if err != nil {
+		return err
+	}
+	hash, err := repository.Storer.SetEncodedObject(blob)
+	if err != nil {
+		return err
+	}
+
+	entry, err := idx.Entry(filePath)
+	if err == index.ErrEntryNotFound {
+		entry = idx.Add(filePath)
+	} else if err != nil {
+		return err
+	}
+	entry.Hash = hash
+	entry.ModifiedAt = info.ModTime()
+	entry.Size = uint32(info.Size())
+	entry.Mode, err = filemode.NewFromOSFileMode(info.Mode())
+	return err
+}
+
+// ResetWorktree discards all changes of the worktree and the index of the given repository, including untracked files,
+// so both match the currently checked out commit again.
+func (gitApi GitApi) ResetWorktree(repository git.Repository) error {
+	w, err := repository.Worktree()
+	if err != nil {
+		return err
+	}
+
+	unborn, err := gitApi.IsUnborn(repository)
+	if err != nil {
+		return err
+	} else if unborn {
+		// an unborn branch does not contain any files, so all files become untracked
+		err = repository.Storer.SetIndex(&index.Index{Version: 2})
 	} else {
-		w.Add("./")
-		wStatus, _ := w.Status()
-		log.Debug("worktree status", "status", wStatus)
-
-		_, err := w.Commit("Synchronized Dashboards with tag <"+tag+">", &git.CommitOptions{
-			Author: (*object2.Signature)(&object.Signature{
-				Name: "grafana-dashboard-sync-plugin",
-				When: time.Now(),
-			}),
-		})
+		err = w.Reset(&git.ResetOptions{Mode: git.HardReset})
+	}
+	if err != nil {
+		return err
+	}
+
+	return w.Clean(&git.CleanOptions{Dir: true})
+}
+
+// CommitWorktree commits all staged changes using the given message, author and committer. The commit is signed
+// in case a signer is given.
+func (gitApi GitApi) CommitWorktree(repository git.Repository, message string, author object.Signature, committer object.Signature, signer CommitSigner) error {
+	// get worktree and commit
+	w, err := repository.Worktree()
+	if err != nil {
+		return err
+	}
+
+	hash, err := w.Commit(message, &git.CommitOptions{
+		Author:    &author,
+		Committer: &committer,
+	})
+	if err != nil {
+		return err
+	}
+
+	if signer != nil {
+		return signHeadCommit(repository, hash, signer)
+	}
+	return nil
+}
+
+// helper function to replace the given commit, which HEAD is pointing to, by a signed copy of it
+func signHeadCommit(repository git.Repository, hash plumbing.Hash, signer CommitSigner) error {
+	commit, err := repository.CommitObject(hash)
+	if err != nil {
+		return err
+	}
+
+	encoded := &plumbing.MemoryObject{}
+	err = commit.EncodeWithoutSignature(encoded)
+	if err != nil {
+		return err
+	}
+	reader, err := encoded.Reader()
+	if err != nil {
+		return err
+	}
+	payload, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return err
+	}
+
+	commit.PGPSignature, err = signer.Sign(payload)
+	if err != nil {
+		return err
+	}
+
+	signed := repository.Storer.NewEncodedObject()
+	err = commit.Encode(signed)
+	if err != nil {
+		return err
+	}
+	signedHash, err := repository.Storer.SetEncodedObject(signed)
+	if err != nil {
+		return err
+	}
+
+	// move the branch (or a detached HEAD) to the signed commit
+	head, err := repository.Storer.Reference(plumbing.HEAD)
+	if err != nil {
+		return err
+	}
+	name := plumbing.HEAD
+	if head.Type() != plumbing.HashReference {
+		name = head.Target()
+	}
+	return repository.Storer.SetReference(plumbing.NewHashReference(name, signedHash))
+}
+
+// ChangedFilesSince returns the paths of all files which have been changed on the currently checked out branch since it
+// has been branched off the given branch, which is fetched from the remote repository. Removed files, e.g. the previous
+// files of renamed dashboards, are not included.
+func (gitApi *GitApi) ChangedFilesSince(baseBranch string) ([]string, error) {
+	err := gitApi.fetchBranch(baseBranch)
+	if err != nil {
+		return nil, err
+	}
+
+	baseRef, err := gitApi.repository.Reference(plumbing.NewRemoteReferenceName("origin", baseBranch), true)
+	if err != nil {
+		return nil, err
+	}
+	baseCommit, err := gitApi.repository.CommitObject(baseRef.Hash())
+	if err != nil {
+		return nil, err
+	}
+	head, err := gitApi.repository.Head()
+	if err != nil {
+		return nil, err
+	}
+	headCommit, err := gitApi.repository.CommitObject(head.Hash())
+	if err != nil {
+		return nil, err
+	}
+
+	// changes of the base branch since the branch has been created are not part of the branch
+	mergeBases, err := headCommit.MergeBase(baseCommit)
+	if err != nil {
+		return nil, err
+	}
+	var baseTree *object.Tree
+	if len(mergeBases) > 0 {
+		baseTree, err = mergeBases[0].Tree()
 		if err != nil {
-			log.Fatal("worktree commit error", "error", err.Error())
-			return
+			return nil, err
 		}
 	}
+	headTree, err := headCommit.Tree()
+	if err != nil {
+		return nil, err
+	}
+
+	changes, err := object.DiffTree(baseTree, headTree)
+	if err != nil {
+		return nil, err
+	}
+	paths := make([]string, 0, len(changes))
+	for _, change := range changes {
+		if change.To.Name != "" {
+			paths = append(paths, change.To.Name)
+		}
+	}
+	sort.Strings(paths)
+	return paths, nil
 }
 
-// PushRepo pushes the given repository
-func (gitApi GitApi) PushRepo(repository git.Repository) {
-	// push repo
-	err := repository.Push(&git.PushOptions{
+// IsUnborn returns whether the currently checked out branch of the given repository does not contain any commit yet
+func (gitApi GitApi) IsUnborn(repository git.Repository) (bool, error) {
+	_, err := repository.Head()
+	if err == plumbing.ErrReferenceNotFound {
+		return true, nil
+	}
+	return false, err
+}
+
+// PushRepo pushes the currently checked out branch of the given repository. ErrPushRejected is returned
+// in case the remote branch contains commits which are missing locally.
+func (gitApi GitApi) PushRepo(repository git.Repository) error {
+	head, err := repository.Head()
+	if err != nil {
+		return err
+	}
+
+	// push only the current branch, as other local branches of a cached working copy might be outdated
+	refSpec := config.RefSpec(head.Name().String() + ":" + head.Name().String())
+	err = repository.Push(&git.PushOptions{
 		RemoteName: "origin",
+		RefSpecs:   []config.RefSpec{refSpec},
 		Auth:       gitApi.authenticator,
 	})
-	if err != nil {
-		log.Fatal("push error", "error", err.Error())
+	if err == git.NoErrAlreadyUpToDate {
+		return nil
+	} else if err != nil && isRejectedPush(err) {
+		return fmt.Errorf("%w: %v", ErrPushRejected, err)
 	}
+	return err
+}
+
+// helper function to determine whether a push error was caused by a non-fast-forward update,
+// which is either detected locally or reported by the remote repository
+func isRejectedPush(err error) bool {
+	message := err.Error()
+	return strings.Contains(message, "non-fast-forward") || strings.Contains(message, "fetch first")
 }
 
 func (gitApi GitApi) GetLatestCommitId(repository git.Repository) (string, error, string) {
@@ -183,19 +878,21 @@ func (gitApi GitApi) GetLatestCommitId(repository git.Repository) (string, error
 	return commit.ID().String(), nil, ""
 }
 
-// GetFileContent get the given content of a file from the in memory filesystem
-func (gitApi GitApi) GetFileContent() map[string]map[string][]byte {
-	// read current in memory filesystem to get dirs
-	filesOrDirs, err := gitApi.inMemoryFileSystem.ReadDir("./")
+// GetFileContent get the JSON content of the dashboard files from the worktree filesystem. Jsonnet files are evaluated
+// using the given configuration.
+func (gitApi GitApi) GetFileContent(jsonnetConfiguration JsonnetConfiguration) map[string]map[string][]byte {
+	// read current worktree filesystem to get dirs
+	filesOrDirs, err := gitApi.fileSystem.ReadDir("./")
 	if err != nil {
-		log.Fatal("inMemoryFileSystem error", "error", err)
+		log.Fatal("fileSystem error", "error", err)
 		return nil
 	}
 
 	var dirMap []string
 
 	for _, fileOrDir := range filesOrDirs {
-		if fileOrDir.IsDir() {
+		// directories of the Jsonnet library paths are no Grafana folders
+		if fileOrDir.IsDir() && fileOrDir.Name() != git.GitDirName && !jsonnetConfiguration.isLibraryDirectory(fileOrDir.Name(), false) {
 			dirName := fileOrDir.Name()
 			dirMap = append(dirMap, dirName)
 		}
@@ -207,35 +904,230 @@ func (gitApi GitApi) GetFileContent() map[string]map[string][]byte {
 		// prepare fileMap for dir
 		fileMap[dir] = make(map[string][]byte)
 
-		// read current in memory filesystem to get files
-		files, err := gitApi.inMemoryFileSystem.ReadDir("./" + dir + "/")
+		// read current worktree filesystem to get files
+		files, err := gitApi.fileSystem.ReadDir("./" + dir + "/")
 		if err != nil {
-			log.Fatal("inMemoryFileSystem ReadDir error", "error", err)
+			log.Fatal("fileSystem ReadDir error", "error", err)
 			return nil
 		}
 
+		containsLibraries := false
 		for _, file := range files {
 
 			log.Debug("file", "name", file.Name())
 
+			// directories of exploded dashboards are reassembled
 			if file.IsDir() {
+				if jsonnetConfiguration.isLibraryDirectory(dir+"/"+file.Name(), true) {
+					containsLibraries = true
+					continue
+				}
+				byteFile, err := gitApi.readExplodedDashboard(dir + "/" + file.Name())
+				if err != nil {
+					log.WithFields(log.Fields{
+						"directory": dir + "/" + file.Name(),
+						"error":     err,
+					}).Fatal("Failed to reassemble exploded dashboard.")
+				} else if byteFile != nil {
+					fileMap[dir][file.Name()+"/"] = byteFile
+				}
 				continue
 			}
 
-			src, err := gitApi.inMemoryFileSystem.Open("./" + dir + "/" + file.Name())
+			// only dashboard files are imported, YAML files are converted into JSON and Jsonnet files are evaluated
+			extension := strings.ToLower(filepath.Ext(file.Name()))
+			if extension == ".libsonnet" {
+				containsLibraries = true
+			}
+			if !isDashboardFileExtension(extension) || isFolderMetadataFile(file.Name()) {
+				continue
+			}
 
-			if err != nil {
-				log.Fatal("inMemoryFileSystem Open error", "error", err)
-				return nil
+			if extension == ".jsonnet" {
+				byteFile, err := jsonnetConfiguration.evaluate(gitApi.fileSystem, dir+"/"+file.Name())
+				if err != nil {
+					log.WithFields(log.Fields{
+						"file":  dir + "/" + file.Name(),
+						"error": err,
+					}).Fatal("Failed to evaluate Jsonnet file.")
+				}
+				fileMap[dir][file.Name()] = byteFile
+				continue
 			}
-			byteFile, err := ioutil.ReadAll(src)
+
+			byteFile, err := gitApi.readDashboardFile(dir + "/" + file.Name())
 			if err != nil {
 				log.Fatal("read error", "error", err)
 			} else {
 				fileMap[dir][file.Name()] = byteFile
-				src.Close()
 			}
 		}
+
+		// directories only containing Jsonnet libraries are no Grafana folders either
+		if containsLibraries && len(fileMap[dir]) == 0 {
+			delete(fileMap, dir)
+		}
 	}
 	return fileMap
 }
+
+// ReadFile returns the content of the file with the given path in the worktree filesystem
+func (gitApi GitApi) ReadFile(filePath string) ([]byte, error) {
+	src, err := gitApi.fileSystem.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer src.Close()
+	return ioutil.ReadAll(src)
+}
+
+// helper function to read the JSON or YAML file with the given path from the worktree filesystem as JSON
+func (gitApi GitApi) readDashboardFile(filePath string) ([]byte, error) {
+	byteFile, err := gitApi.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+	if strings.ToLower(filepath.Ext(filePath)) != ".json" {
+		return YamlToJson(byteFile)
+	}
+	return byteFile, nil
+}
+
+// ReadDashboard returns the JSON of the dashboard with the given path in the worktree filesystem, which is either a
+// dashboard file or the directory of an exploded dashboard. Returns nil in case no dashboard exists at the given path.
+func (gitApi GitApi) ReadDashboard(dashboardPath string, jsonnetConfiguration JsonnetConfiguration) ([]byte, error) {
+	info, err := gitApi.fileSystem.Stat(dashboardPath)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	extension := strings.ToLower(filepath.Ext(dashboardPath))
+	switch {
+	case info.IsDir():
+		return gitApi.readExplodedDashboard(dashboardPath)
+	case extension == ".jsonnet":
+		return jsonnetConfiguration.evaluate(gitApi.fileSystem, dashboardPath)
+	case isDashboardFileExtension(extension):
+		return gitApi.readDashboardFile(dashboardPath)
+	}
+	return nil, nil
+}
+
+// helper function to reassemble the exploded dashboard stored in the given directory of the worktree filesystem.
+// Returns nil in case the directory does not contain an exploded dashboard.
+func (gitApi GitApi) readExplodedDashboard(directory string) ([]byte, error) {
+	parts := make(map[string][]byte)
+	for _, subdirectory := range []string{"", explodedPanelsDirectory + "/", explodedRowsDirectory + "/"} {
+		files, err := gitApi.fileSystem.ReadDir(directory + "/" + subdirectory)
+		if err != nil {
+			continue
+		}
+
+		for _, file := range files {
+			extension := strings.ToLower(filepath.Ext(file.Name()))
+			if file.IsDir() || extension == ".jsonnet" || !isDashboardFileExtension(extension) {
+				continue
+			}
+
+			content, err := gitApi.readDashboardFile(directory + "/" + subdirectory + file.Name())
+			if err != nil {
+				return nil, err
+			}
+			parts[subdirectory+strings.TrimSuffix(file.Name(), filepath.Ext(file.Name()))] = content
+		}
+	}
+
+	if _, ok := parts[explodedMetadataFile]; !ok {
+		return nil, nil
+	}
+	return AssembleDashboard(parts)
+}
+
+// RemoveStaleFiles removes all files in the given directory of the worktree filesystem, including its subdirectories,
+// which are not contained in the given files. Returns the paths of the removed files.
+func (gitApi GitApi) RemoveStaleFiles(directory string, files map[string]ExportFile) ([]string, error) {
+	return gitApi.removeFiles(directory, func(filePath string) bool {
+		_, ok := files[filePath]
+		return ok
+	})
+}
+
+// RemoveStaleDashboardFiles removes all dashboard files and folder metadata files in the folder directories of the
+// worktree filesystem, which are not kept according to the given function. Other files and files in the root directory,
+// e.g. the synchronization state, are not removed. Returns the paths of the removed files.
+func (gitApi GitApi) RemoveStaleDashboardFiles(keep func(filePath string) bool) ([]string, error) {
+	entries, err := gitApi.fileSystem.ReadDir("./")
+	if err != nil {
+		return nil, err
+	}
+
+	keepDashboardFile := func(filePath string) bool {
+		return !isDashboardFileExtension(strings.ToLower(filepath.Ext(filePath))) || keep(filePath)
+	}
+
+	var removedFiles []string
+	for _, entry := range entries {
+		if entry.IsDir() && entry.Name() != git.GitDirName {
+			removed, err := gitApi.removeFiles(entry.Name(), keepDashboardFile)
+			if err != nil {
+				return nil, err
+			}
+			removedFiles = append(removedFiles, removed...)
+		}
+	}
+	return removedFiles, nil
+}
+
+// RemoveFiles removes the file or the directory with the given path from the worktree filesystem. Returns the paths of the
+// removed files, which are empty in case the path does not exist.
+func (gitApi GitApi) RemoveFiles(filePath string) ([]string, error) {
+	info, err := gitApi.fileSystem.Lstat(filePath)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	if info.IsDir() {
+		return gitApi.removeFiles(filePath, func(string) bool {
+			return false
+		})
+	}
+	err = gitApi.fileSystem.Remove(filePath)
+	if err != nil {
+		return nil, err
+	}
+	return []string{filePath}, nil
+}
+
+// helper function to remove all files in the given directory, including its subdirectories, which are not kept according
+// to the given function
+func (gitApi GitApi) removeFiles(directory string, keep func(filePath string) bool) ([]string, error) {
+	entries, err := gitApi.fileSystem.ReadDir(directory)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var removedFiles []string
+	for _, entry := range entries {
+		entryPath := directory + "/" + entry.Name()
+		if entry.IsDir() {
+			removed, err := gitApi.removeFiles(entryPath, keep)
+			if err != nil {
+				return nil, err
+			}
+			removedFiles = append(removedFiles, removed...)
+		} else if !keep(entryPath) {
+			err = gitApi.fileSystem.Remove(entryPath)
+			if err != nil {
+				return nil, err
+			}
+			removedFiles = append(removedFiles, entryPath)
+		}
+	}
+	return removedFiles, nil
+}
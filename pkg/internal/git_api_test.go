@@ -0,0 +1,102 @@
+package internal
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+	"time"
+
+	"gopkg.in/src-d/go-billy.v4/memfs"
+	"gopkg.in/src-d/go-billy.v4/util"
+	"gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+	"gopkg.in/src-d/go-git.v4/storage/memory"
+)
+
+func TestGetFileContentSkipsJsonnetLibraries(t *testing.T) {
+	fileSystem := memfs.New()
+	files := map[string]string{
+		"Team/dashboard.jsonnet":                 `local lib = import "grafonnet/dashboard.libsonnet"; lib.new("Dashboard")`,
+		"vendor/grafonnet/dashboard.libsonnet":   `{ new(title):: { title: title } }`,
+		"vendor/grafonnet/example.json":          `{"title": "Example"}`,
+		"vendor/example.json":                    `{"title": "Example"}`,
+		"lib/helpers.libsonnet":                  `{}`,
+		"Shared/nested/library/helper.libsonnet": `{}`,
+	}
+	for filePath, content := range files {
+		if err := util.WriteFile(fileSystem, filePath, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	gitApi := GitApi{fileSystem: fileSystem}
+	fileMap := gitApi.GetFileContent(JsonnetConfiguration{LibraryPaths: []string{"vendor", "Shared/nested/library"}})
+
+	if len(fileMap) != 1 {
+		t.Fatalf("expected only the folder 'Team', got %v", fileMap)
+	}
+	dashboardJson, ok := fileMap["Team"]["dashboard.jsonnet"]
+	if !ok {
+		t.Fatalf("expected the dashboard of folder 'Team', got %v", fileMap["Team"])
+	}
+	var dashboard map[string]interface{}
+	if err := json.Unmarshal(dashboardJson, &dashboard); err != nil {
+		t.Fatal(err)
+	}
+	if dashboard["title"] != "Dashboard" {
+		t.Errorf("expected the evaluated dashboard, got %s", dashboardJson)
+	}
+}
+
+func TestResetWorktreeDiscardsStagedFiles(t *testing.T) {
+	fileSystem := memfs.New()
+	repository, err := git.Init(memory.NewStorage(), fileSystem)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gitApi := GitApi{fileSystem: fileSystem, repository: repository}
+	signature := object.Signature{Name: "test", When: time.Now()}
+
+	gitApi.AddFileWithContent("Team/committed.json", `{"title": "Committed"}`)
+	gitApi.AddFileWithContent("Team/removed.json", `{"title": "Removed"}`)
+	if _, err = gitApi.StageFiles(*repository, []string{"Team"}); err != nil {
+		t.Fatal(err)
+	}
+	if err = gitApi.CommitWorktree(*repository, "initial", signature, signature, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	gitApi.AddFileWithContent("Team/committed.json", `{"title": "Changed"}`)
+	gitApi.AddFileWithContent("Team/added.json", `{"title": "Added"}`)
+	if err = fileSystem.Remove("Team/removed.json"); err != nil {
+		t.Fatal(err)
+	}
+	changedFiles, err := gitApi.StageFiles(*repository, []string{"Team", "Team/removed.json"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changedFiles) != 3 {
+		t.Fatalf("expected three staged changes, got %v", changedFiles)
+	}
+
+	if err = gitApi.ResetWorktree(*repository); err != nil {
+		t.Fatal(err)
+	}
+	worktree, err := repository.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	status, err := worktree.Status()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !status.IsClean() {
+		t.Errorf("expected a clean worktree and index, got %v", status)
+	}
+	if content, err := gitApi.ReadFile("Team/committed.json"); err != nil || string(content) != `{"title": "Committed"}` {
+		t.Errorf("expected the committed content, got %s (%v)", content, err)
+	}
+	if _, err = fileSystem.Stat("Team/added.json"); !os.IsNotExist(err) {
+		t.Errorf("expected the added file to be removed, got %v", err)
+	}
+}
@@ -2,15 +2,30 @@ package internal
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
 	"strings"
 
 	sdk "github.com/NovatecConsulting/grafana-api-go-sdk"
 	log "github.com/sirupsen/logrus"
 )
 
+// the number of dashboards requested per page of the search, Grafana allows at most 5000
+const searchPageSize = 1000
+
+// the number of folders requested per page of the folder listing
+const folderPageSize = 1000
+
 // GrafanaApi access to grafana api
 type GrafanaApi struct {
 	grafanaClient *sdk.Client
+	// the URL and token are used for requests which are not supported by the client
+	grafanaURL string
+	apiToken   string
 }
 
 type DashboardWithCustomFields struct {
@@ -21,15 +36,45 @@ type DashboardWithCustomFields struct {
 // NewGrafanaApi creates a new GrafanaApi instance
 func NewGrafanaApi(grafanaURL string, apiToken string) *GrafanaApi {
 	client, _ := sdk.NewClient(grafanaURL, apiToken, sdk.DefaultHTTPClient)
-	grafanaApi := GrafanaApi{client}
+	grafanaApi := GrafanaApi{client, strings.TrimSuffix(grafanaURL, "/"), apiToken}
 	return &grafanaApi
 }
 
-// SearchDashboardsWithTag returns all dashboards with the given tag
-func (grafanaApi GrafanaApi) SearchDashboardsWithTag(tag string) ([]sdk.FoundBoard, error) {
-	searchParam := sdk.SearchTag(tag)
-	foundDashboards, err := grafanaApi.grafanaClient.Search(context.Background(), searchParam)
-	return foundDashboards, err
+// SearchDashboards returns all dashboards with the given tags, optionally only the starred ones. The search results
+// are requested page by page, because Grafana limits the number of results per request.
+func (grafanaApi GrafanaApi) SearchDashboards(tags []string, starred bool) ([]sdk.FoundBoard, error) {
+	searchParams := []sdk.SearchParam{sdk.SearchType(sdk.SearchTypeDashboard), sdk.SearchLimit(searchPageSize)}
+	for _, tag := range tags {
+		searchParams = append(searchParams, sdk.SearchTag(tag))
+	}
+	if starred {
+		searchParams = append(searchParams, sdk.SearchStarred(true))
+	}
+
+	var foundDashboards []sdk.FoundBoard
+	found := make(map[string]bool)
+	for page := uint(1); ; page++ {
+		pageDashboards, err := grafanaApi.grafanaClient.Search(context.Background(), append(searchParams, sdk.SearchPage(page))...)
+		if err != nil {
+			return nil, err
+		}
+
+		// dashboards created while paging can shift the results, so dashboards may be returned twice
+		for _, dashboard := range pageDashboards {
+			if !found[dashboard.UID] {
+				found[dashboard.UID] = true
+				foundDashboards = append(foundDashboards, dashboard)
+			}
+		}
+		log.WithFields(log.Fields{
+			"page":   page,
+			"amount": len(pageDashboards),
+		}).Debug("Fetched page of dashboards.")
+
+		if len(pageDashboards) < searchPageSize {
+			return foundDashboards, nil
+		}
+	}
 }
 
 // GetDashboardObjectByUID return Dashboard by the given UID as object
@@ -61,9 +106,83 @@ func (grafanaApi GrafanaApi) CreateOrUpdateDashboardObjectByID(rawDashboard []by
 	return statusMessage
 }
 
-// CreateFolder create a folder in Grafana
-func (grafanaApi GrafanaApi) CreateFolder(folderName string) (*sdk.Folder, error) {
-	folder := sdk.Folder{Title: folderName}
+// GetDashboardVersions returns the most recent versions of the dashboard with the given ID, starting with the newest one
+func (grafanaApi GrafanaApi) GetDashboardVersions(dashboardId int, limit int) ([]sdk.Version, error) {
+	return grafanaApi.grafanaClient.GetAllDashboardVersions(context.Background(), dashboardId, limit)
+}
+
+// GetDashboardVersion returns the dashboard JSON of the given version of the dashboard with the given ID
+func (grafanaApi GrafanaApi) GetDashboardVersion(dashboardId int, version int) ([]byte, error) {
+	request, err := http.NewRequestWithContext(context.Background(), http.MethodGet,
+		fmt.Sprintf("%s/api/dashboards/id/%d/versions/%d", grafanaApi.grafanaURL, dashboardId, version), nil)
+	if err != nil {
+		return nil, err
+	}
+	if parts := strings.SplitN(grafanaApi.apiToken, ":", 2); len(parts) == 2 {
+		request.SetBasicAuth(parts[0], parts[1])
+	} else if grafanaApi.apiToken != "" {
+		request.Header.Set("Authorization", "Bearer "+grafanaApi.apiToken)
+	}
+	request.Header.Set("Accept", "application/json")
+
+	response, err := sdk.DefaultHTTPClient.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	raw, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return nil, err
+	}
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("HTTP error %d: returns %s", response.StatusCode, raw)
+	}
+
+	var dashboardVersion struct {
+		Data json.RawMessage `json:"data"`
+	}
+	err = json.Unmarshal(raw, &dashboardVersion)
+	if err != nil {
+		return nil, err
+	}
+	return dashboardVersion.Data, nil
+}
+
+// GetAllFolders returns all folders in Grafana. The folders are requested page by page, because Grafana limits the
+// number of folders per request.
+func (grafanaApi GrafanaApi) GetAllFolders() ([]sdk.Folder, error) {
+	var folders []sdk.Folder
+	found := make(map[string]bool)
+	for page := uint(1); ; page++ {
+		pageFolders, err := grafanaApi.grafanaClient.GetAllFolders(context.Background(), sdk.Limit(folderPageSize), folderPage(page))
+		if err != nil {
+			return nil, err
+		}
+
+		// folders created while paging can shift the results, so folders may be returned twice
+		for _, folder := range pageFolders {
+			if !found[folder.UID] {
+				found[folder.UID] = true
+				folders = append(folders, folder)
+			}
+		}
+
+		if len(pageFolders) < folderPageSize {
+			return folders, nil
+		}
+	}
+}
+
+// helper function to request the given page of the folder listing, which is not supported by the client
+func folderPage(page uint) sdk.GetFolderParams {
+	return func(values url.Values) {
+		values.Set("page", strconv.FormatUint(uint64(page), 10))
+	}
+}
+
+// CreateFolder create a folder in Grafana, the UID is generated by Grafana if empty
+func (grafanaApi GrafanaApi) CreateFolder(folderName string, uid string) (*sdk.Folder, error) {
+	folder := sdk.Folder{Title: folderName, UID: uid}
 	folder, err := grafanaApi.grafanaClient.CreateFolder(context.Background(), folder)
 	if err != nil {
 		return &folder, err
@@ -80,7 +199,7 @@ func (grafanaApi GrafanaApi) GetFolder(folderName string) (*sdk.Folder, error) {
 		}, nil
 	}
 
-	folders, err := grafanaApi.grafanaClient.GetAllFolders(context.Background())
+	folders, err := grafanaApi.GetAllFolders()
 	if err != nil {
 		return nil, err
 	}
@@ -92,13 +211,16 @@ func (grafanaApi GrafanaApi) GetFolder(folderName string) (*sdk.Folder, error) {
 	return nil, nil
 }
 
-// DeleteTagFromDashboardObjectByID delete the given tag from the Dashboard object
-func (grafanaApi GrafanaApi) DeleteTagFromDashboardObjectByID(dashboard sdk.Board, tag string) sdk.Board {
-	for i, iTag := range dashboard.Tags {
-		if iTag == tag {
-			dashboard.Tags = append(dashboard.Tags[:i], dashboard.Tags[i+1:]...)
-			break
+// GetUserByLogin returns the user with the given login or nil if no such user exists. Requires admin permissions.
+func (grafanaApi GrafanaApi) GetUserByLogin(login string) (*sdk.User, error) {
+	result, err := grafanaApi.grafanaClient.SearchUsersWithPaging(context.Background(), &login, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	for _, user := range result.Users {
+		if user.Login == login {
+			return &user, nil
 		}
 	}
-	return dashboard
+	return nil, nil
 }
@@ -0,0 +1,95 @@
+package internal
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	sdk "github.com/NovatecConsulting/grafana-api-go-sdk"
+)
+
+func TestGetAllFoldersPagesThroughFolders(t *testing.T) {
+	var pages []string
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		if request.URL.Path != "/api/folders" {
+			http.NotFound(writer, request)
+			return
+		}
+		pages = append(pages, request.URL.Query().Get("page"))
+		limit, _ := strconv.Atoi(request.URL.Query().Get("limit"))
+		page, _ := strconv.Atoi(request.URL.Query().Get("page"))
+
+		// the second page contains the last folder of the first page again and the remaining folders
+		total := limit + 2
+		var folders []sdk.Folder
+		for i := (page - 1) * (limit - 1); i < total && len(folders) < limit; i++ {
+			folders = append(folders, sdk.Folder{ID: i + 1, UID: fmt.Sprintf("uid-%d", i), Title: fmt.Sprintf("Folder %d", i)})
+		}
+		json.NewEncoder(writer).Encode(folders)
+	}))
+	defer server.Close()
+	grafanaApi := NewGrafanaApi(server.URL, "token")
+
+	folders, err := grafanaApi.GetAllFolders()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(folders) != folderPageSize+2 {
+		t.Errorf("expected %d folders, got %d", folderPageSize+2, len(folders))
+	}
+	if len(pages) != 2 || pages[0] != "1" || pages[1] != "2" {
+		t.Errorf("expected two pages to be requested, got %v", pages)
+	}
+
+	folder, err := grafanaApi.GetFolder(fmt.Sprintf("Folder %d", folderPageSize+1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if folder == nil || folder.UID != fmt.Sprintf("uid-%d", folderPageSize+1) {
+		t.Errorf("expected the folder of the second page to be found, got %+v", folder)
+	}
+}
+
+func TestSearchDashboardsPagesThroughResults(t *testing.T) {
+	var pages []string
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		if request.URL.Path != "/api/search" {
+			http.NotFound(writer, request)
+			return
+		}
+		pages = append(pages, request.URL.Query().Get("page"))
+		limit, _ := strconv.Atoi(request.URL.Query().Get("limit"))
+		page, _ := strconv.Atoi(request.URL.Query().Get("page"))
+
+		// the second page contains the last dashboard of the first page again and the remaining dashboards
+		total := limit + 2
+		var dashboards []sdk.FoundBoard
+		for i := (page - 1) * (limit - 1); i < total && len(dashboards) < limit; i++ {
+			dashboards = append(dashboards, sdk.FoundBoard{ID: uint(i + 1), UID: fmt.Sprintf("uid-%d", i), Title: fmt.Sprintf("Dashboard %d", i), Type: "dash-db"})
+		}
+		json.NewEncoder(writer).Encode(dashboards)
+	}))
+	defer server.Close()
+	grafanaApi := NewGrafanaApi(server.URL, "token")
+
+	dashboards, err := grafanaApi.SearchDashboards([]string{"sync"}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dashboards) != searchPageSize+2 {
+		t.Errorf("expected %d dashboards, got %d", searchPageSize+2, len(dashboards))
+	}
+	found := make(map[string]bool)
+	for _, dashboard := range dashboards {
+		if found[dashboard.UID] {
+			t.Errorf("expected dashboard %s to be returned once", dashboard.UID)
+		}
+		found[dashboard.UID] = true
+	}
+	if len(pages) != 2 || pages[0] != "1" || pages[1] != "2" {
+		t.Errorf("expected two pages to be requested, got %v", pages)
+	}
+}
@@ -0,0 +1,171 @@
+package internal
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	sdk "github.com/NovatecConsulting/grafana-api-go-sdk"
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/src-d/go-git.v4"
+)
+
+// a previous version of a dashboard which is committed individually
+type dashboardVersionCommit struct {
+	// the path of the dashboard
+	Dashboard string
+	// the files of the dashboard version, mapped by their path
+	Files map[string]ExportFile
+	// the Grafana version number and the message given when the version was saved
+	Version int
+	Message string
+	// the Grafana user who created the version and when it was created
+	CreatedBy string
+	Created   time.Time
+}
+
+// Returns the commits of the versions of the given dashboard which have been created in Grafana since its last export,
+// excluding the current version. In case the dashboard has not been exported yet, all its versions are returned.
+func (s *Synchronization) dashboardVersionCommits(dashboard sdk.Board, dashboardPath string, fileExtension string, syncPattern *tagPattern) ([]dashboardVersionCommit, error) {
+	configuration := s.options.PushConfiguration
+
+	var lastVersion uint
+	if dashboardState, ok := s.state.Push[dashboard.UID]; ok {
+		lastVersion = dashboardState.GrafanaVersion
+	}
+	if dashboard.Version <= lastVersion+1 {
+		return nil, nil
+	}
+
+	versions, err := s.grafanaApi.GetDashboardVersions(int(dashboard.ID), int(dashboard.Version-lastVersion))
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(versions, func(i, j int) bool {
+		return versions[i].Version < versions[j].Version
+	})
+
+	var commits []dashboardVersionCommit
+	for _, version := range versions {
+		if version.Version <= int(lastVersion) || version.Version >= int(dashboard.Version) {
+			continue
+		}
+
+		versionJson, err := s.grafanaApi.GetDashboardVersion(int(dashboard.ID), version.Version)
+		if err != nil {
+			return nil, err
+		}
+		var board sdk.Board
+		err = json.Unmarshal(versionJson, &board)
+		if err != nil {
+			return nil, err
+		}
+		if !configuration.PushTags && !configuration.Backup {
+			board.Tags, _ = syncPattern.removeFrom(board.Tags)
+		}
+		dashboardJson, err := json.Marshal(DashboardWithCustomFields{board, s.options.JobName})
+		if err != nil {
+			return nil, err
+		}
+
+		dashboardFiles, err := s.dashboardExportFiles(dashboardJson, dashboardPath, fileExtension)
+		if err != nil {
+			return nil, err
+		}
+		files := make(map[string]ExportFile, len(dashboardFiles))
+		for filePath, content := range dashboardFiles {
+			files[filePath] = ExportFile{
+				Dashboard: dashboardPath,
+				Content:   content,
+				UpdatedBy: version.CreatedBy,
+				Updated:   version.Created,
+			}
+		}
+
+		commits = append(commits, dashboardVersionCommit{
+			Dashboard: dashboardPath,
+			Files:     files,
+			Version:   version.Version,
+			Message:   version.Message,
+			CreatedBy: version.CreatedBy,
+			Created:   version.Created,
+		})
+	}
+	return commits, nil
+}
+
+// Commits the given dashboard versions in the order they have been created, each attributed to the Grafana user who
+// created it. Versions which do not change the exported files are not committed. Returns the paths of the committed dashboards.
+func (s *Synchronization) commitDashboardVersions(repository *git.Repository, commits []dashboardVersionCommit) ([]string, error) {
+	configuration := s.options.PushConfiguration
+
+	sort.SliceStable(commits, func(i, j int) bool {
+		return commits[i].Created.Before(commits[j].Created)
+	})
+
+	var committedDashboards []string
+	for _, commit := range commits {
+		paths := make([]string, 0, len(commit.Files))
+		for path, file := range commit.Files {
+			s.gitApi.AddFileWithContent(path, string(file.Content))
+			paths = append(paths, path)
+		}
+		if configuration.Layout == "exploded" {
+			removedFiles, err := s.gitApi.RemoveStaleFiles(commit.Dashboard, commit.Files)
+			if err != nil {
+				return nil, err
+			}
+			paths = append(paths, removedFiles...)
+		}
+
+		changedFiles, err := s.gitApi.StageFiles(*repository, paths)
+		if err != nil {
+			return nil, err
+		}
+		if len(changedFiles) == 0 {
+			continue
+		}
+
+		log.WithFields(log.Fields{
+			"dashboard": commit.Dashboard,
+			"version":   commit.Version,
+			"author":    commit.CreatedBy,
+		}).Debug("Committing dashboard version.")
+		err = s.gitApi.CommitWorktree(*repository, dashboardVersionCommitMessage(commit), s.editorSignature(commit.CreatedBy, commit.Created),
+			configuration.Commit.committer(time.Now()), s.signer)
+		if err != nil {
+			return nil, err
+		}
+		committedDashboards = append(committedDashboards, commit.Dashboard)
+	}
+
+	return committedDashboards, nil
+}
+
+// Returns the commit message of the given dashboard version, containing the message given when the version was saved.
+func dashboardVersionCommitMessage(commit dashboardVersionCommit) string {
+	message := fmt.Sprintf("Update dashboard '%s' to version %d", commit.Dashboard, commit.Version)
+	if commit.Message != "" {
+		message += "\n\n" + commit.Message
+	}
+	return message
+}
+
+// Returns the sorted union of the given dashboard paths.
+func mergeDashboardPaths(paths []string, otherPaths []string) []string {
+	if len(paths) == 0 {
+		return otherPaths
+	}
+
+	merged := make(map[string]bool)
+	for _, path := range append(paths, otherPaths...) {
+		merged[path] = true
+	}
+	result := make([]string, 0, len(merged))
+	for path := range merged {
+		result = append(result, path)
+	}
+	sort.Strings(result)
+	return result
+}
@@ -0,0 +1,124 @@
+package internal
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/google/go-jsonnet"
+	"gopkg.in/src-d/go-billy.v4"
+)
+
+type JsonnetConfiguration struct {
+	// directories searched for imported libraries, e.g. "vendor". Relative paths are resolved in the repository,
+	// absolute paths on the local filesystem
+	LibraryPaths []string `yaml:"library-paths"`
+	// external variables passed as strings, accessible via std.extVar
+	ExtVars map[string]string `yaml:"ext-vars"`
+	// external variables passed as Jsonnet code, accessible via std.extVar
+	ExtCode map[string]string `yaml:"ext-code"`
+}
+
+// Checks whether the given directory of the repository is one of the library paths. In case parent is set, it is also
+// checked whether the directory contains one of the library paths.
+func (configuration JsonnetConfiguration) isLibraryDirectory(directory string, parent bool) bool {
+	for _, libraryPath := range configuration.LibraryPaths {
+		if filepath.IsAbs(libraryPath) {
+			continue
+		}
+		libraryPath = path.Clean(libraryPath)
+		if libraryPath == directory || (parent && strings.HasPrefix(libraryPath, directory+"/")) {
+			return true
+		}
+	}
+	return false
+}
+
+// Checks whether the given file of the repository is located in one of the library paths.
+func (configuration JsonnetConfiguration) isLibraryFile(filePath string) bool {
+	for directory := path.Dir(filePath); directory != "." && directory != "/"; directory = path.Dir(directory) {
+		if configuration.isLibraryDirectory(directory, false) {
+			return true
+		}
+	}
+	return false
+}
+
+// Evaluates the Jsonnet file with the given path in the given filesystem and returns the resulting JSON document.
+func (configuration JsonnetConfiguration) evaluate(fileSystem billy.Filesystem, filePath string) ([]byte, error) {
+	vm := jsonnet.MakeVM()
+	vm.Importer(&jsonnetImporter{
+		fileSystem:   fileSystem,
+		libraryPaths: configuration.LibraryPaths,
+		cache:        make(map[string]jsonnet.Contents),
+	})
+	for name, value := range configuration.ExtVars {
+		vm.ExtVar(name, value)
+	}
+	for name, code := range configuration.ExtCode {
+		vm.ExtCode(name, code)
+	}
+
+	document, err := vm.EvaluateFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(document), nil
+}
+
+// resolves imports of Jsonnet files relative to the importing file and in the library paths. Files of the repository
+// are read from its worktree filesystem, which is not necessarily located on the local filesystem.
+type jsonnetImporter struct {
+	fileSystem   billy.Filesystem
+	libraryPaths []string
+	// the contents of already imported files, as the same instance must be returned for each import of a file
+	cache map[string]jsonnet.Contents
+}
+
+func (importer *jsonnetImporter) Import(importedFrom string, importedPath string) (jsonnet.Contents, string, error) {
+	var candidates []string
+	if filepath.IsAbs(importedPath) {
+		candidates = append(candidates, importedPath)
+	} else {
+		if filepath.IsAbs(importedFrom) {
+			candidates = append(candidates, filepath.Join(filepath.Dir(importedFrom), importedPath))
+		} else {
+			candidates = append(candidates, path.Join(path.Dir(importedFrom), importedPath))
+		}
+		for _, libraryPath := range importer.libraryPaths {
+			if filepath.IsAbs(libraryPath) {
+				candidates = append(candidates, filepath.Join(libraryPath, importedPath))
+			} else {
+				candidates = append(candidates, path.Join(libraryPath, importedPath))
+			}
+		}
+	}
+
+	for _, candidate := range candidates {
+		if contents, ok := importer.cache[candidate]; ok {
+			return contents, candidate, nil
+		}
+		content, err := importer.read(candidate)
+		if err == nil {
+			importer.cache[candidate] = jsonnet.MakeContents(string(content))
+			return importer.cache[candidate], candidate, nil
+		}
+	}
+	return jsonnet.Contents{}, "", fmt.Errorf("cannot find %q in the directory of %q or in the library paths", importedPath, importedFrom)
+}
+
+// helper function to read the given file from the local filesystem, if the path is absolute, otherwise from the repository
+func (importer *jsonnetImporter) read(filePath string) ([]byte, error) {
+	if filepath.IsAbs(filePath) {
+		return ioutil.ReadFile(filePath)
+	}
+
+	file, err := importer.fileSystem.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return ioutil.ReadAll(file)
+}
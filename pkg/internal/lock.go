@@ -0,0 +1,50 @@
+package internal
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// interval between two attempts to acquire a lock held by another process
+const lockRetryInterval = 500 * time.Millisecond
+
+// FileLock is an exclusive lock backed by a file, which is released automatically when the owning process exits
+type FileLock struct {
+	file *os.File
+}
+
+// AcquireFileLock locks the given file, waiting at most the given timeout in case it is held by another process
+func AcquireFileLock(path string, timeout time.Duration) (*FileLock, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	deadline := time.Now().Add(timeout)
+	for {
+		locked, err := tryLockFile(file)
+		if err != nil {
+			file.Close()
+			return nil, err
+		}
+		if locked {
+			return &FileLock{file}, nil
+		}
+		if time.Now().After(deadline) {
+			file.Close()
+			return nil, fmt.Errorf("timed out after %s waiting for lock %q", timeout, path)
+		}
+
+		log.WithField("lock-file", path).Debug("Waiting for lock held by another process..")
+		time.Sleep(lockRetryInterval)
+	}
+}
+
+// Release releases the lock
+func (lock *FileLock) Release() error {
+	defer lock.file.Close()
+	return unlockFile(lock.file)
+}
@@ -0,0 +1,195 @@
+package internal
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// the prefix of branches created for pull requests, used in case no prefix is configured
+const defaultPullRequestBranchPrefix = "grafana-dashboard-sync/"
+
+// the number of pull requests requested per page when searching the open pull request of a job
+const pullRequestPageSize = 50
+
+// the templates of title and description of pull requests, used in case no templates are configured
+const defaultPullRequestTitle = "Synchronize Grafana dashboards of job '{{.JobName}}'"
+const defaultPullRequestDescription = "Dashboards exported from {{.GrafanaUrl}}:\n{{range .Dashboards}}\n- {{.}}{{end}}\n"
+
+type PullRequestConfiguration struct {
+	// whether the dashboards are pushed into a new branch and a pull request into the push branch is opened
+	Enable bool `yaml:"enable"`
+	// the provider hosting the repository, one of "github", "gitlab", "gitea" (also for Forgejo) or "bitbucket"
+	Provider string `yaml:"provider"`
+	// the base URL of the provider's API, e.g. "https://api.github.com" or "https://gitlab.example.com/api/v4"
+	ApiUrl string `yaml:"api-url"`
+	// the repository to open pull requests in, e.g. "owner/repository" or the path of a GitLab project
+	Repository string `yaml:"repository"`
+	// file or environment variable containing the API token
+	TokenFile string `yaml:"token-file"`
+	TokenEnv  string `yaml:"token-env"`
+	// the prefix of the created branches, which is followed by the job name
+	BranchPrefix string `yaml:"branch-prefix"`
+	// templates of the pull request's title and description, supporting the same placeholders as the commit message
+	Title       string `yaml:"title"`
+	Description string `yaml:"description"`
+}
+
+// PullRequest is a pull request (or merge request) opened by a PullRequestProvider
+type PullRequest struct {
+	ID           string
+	URL          string
+	SourceBranch string
+}
+
+// PullRequestProvider opens and updates pull requests using the API of a Git hosting service
+type PullRequestProvider interface {
+	// FindOpenPullRequest returns an open pull request into the target branch whose source branch starts with the given prefix, or nil
+	FindOpenPullRequest(branchPrefix string, targetBranch string) (*PullRequest, error)
+	// CreatePullRequest opens a pull request from the source into the target branch
+	CreatePullRequest(sourceBranch string, targetBranch string, title string, description string) (*PullRequest, error)
+	// UpdatePullRequest updates title and description of the given pull request
+	UpdatePullRequest(pullRequest *PullRequest, title string, description string) error
+}
+
+// Creates the configured pull request provider. Returns nil in case pull requests are disabled.
+func (configuration PullRequestConfiguration) provider() (PullRequestProvider, error) {
+	if !configuration.Enable {
+		return nil, nil
+	}
+
+	token, err := readSecret(configuration.TokenFile, configuration.TokenEnv)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read API token: %v", err)
+	}
+	tokenValue := strings.TrimSpace(string(token))
+	client := &apiClient{
+		baseUrl:    strings.TrimSuffix(configuration.ApiUrl, "/"),
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+	}
+
+	switch configuration.Provider {
+	case "github":
+		if client.baseUrl == "" {
+			client.baseUrl = "https://api.github.com"
+		}
+		client.authorization = "Bearer " + tokenValue
+		return &githubProvider{client, configuration.Repository, "per_page"}, nil
+	case "gitea":
+		if client.baseUrl == "" {
+			return nil, errors.New("the API URL is required for Gitea")
+		}
+		client.authorization = "token " + tokenValue
+		return &githubProvider{client, configuration.Repository, "limit"}, nil
+	case "gitlab":
+		if client.baseUrl == "" {
+			client.baseUrl = "https://gitlab.com/api/v4"
+		}
+		client.headers = map[string]string{"PRIVATE-TOKEN": tokenValue}
+		return &gitlabProvider{client, configuration.Repository}, nil
+	case "bitbucket":
+		if client.baseUrl == "" {
+			client.baseUrl = "https://api.bitbucket.org/2.0"
+		}
+		client.authorization = "Bearer " + tokenValue
+		return &bitbucketProvider{client, configuration.Repository}, nil
+	default:
+		return nil, fmt.Errorf("invalid pull request provider %q, must be 'github', 'gitlab', 'gitea' or 'bitbucket'", configuration.Provider)
+	}
+}
+
+// Returns the prefix of the branches created for pull requests of the given job.
+func (configuration PullRequestConfiguration) branchPrefix(jobName string) string {
+	prefix := configuration.BranchPrefix
+	if prefix == "" {
+		prefix = defaultPullRequestBranchPrefix
+	}
+	jobName = regexp.MustCompile(`[^a-zA-Z0-9._-]+`).ReplaceAllString(jobName, "-")
+	return prefix + jobName + "/"
+}
+
+// Parses the configured title and description templates or the default templates if none are configured.
+func (configuration PullRequestConfiguration) templates() (*template.Template, *template.Template, error) {
+	title := configuration.Title
+	if title == "" {
+		title = defaultPullRequestTitle
+	}
+	description := configuration.Description
+	if description == "" {
+		description = defaultPullRequestDescription
+	}
+
+	titleTemplate, err := template.New("pull-request-title").Parse(title)
+	if err != nil {
+		return nil, nil, err
+	}
+	descriptionTemplate, err := template.New("pull-request-description").Parse(description)
+	if err != nil {
+		return nil, nil, err
+	}
+	return titleTemplate, descriptionTemplate, nil
+}
+
+// a minimal client for the JSON based REST APIs of the Git hosting services
+type apiClient struct {
+	baseUrl    string
+	httpClient *http.Client
+	// value of the authorization header, if the token is not passed in a provider specific header
+	authorization string
+	// additional headers sent with each request
+	headers map[string]string
+}
+
+// Sends a request to the given API path and decodes the JSON response into the given result, if not nil.
+func (client *apiClient) do(method string, path string, body interface{}, result interface{}) error {
+	var requestBody io.Reader
+	if body != nil {
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		requestBody = bytes.NewReader(encoded)
+	}
+
+	request, err := http.NewRequest(method, client.baseUrl+path, requestBody)
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Accept", "application/json")
+	if body != nil {
+		request.Header.Set("Content-Type", "application/json")
+	}
+	if client.authorization != "" {
+		request.Header.Set("Authorization", client.authorization)
+	}
+	for name, value := range client.headers {
+		request.Header.Set(name, value)
+	}
+
+	response, err := client.httpClient.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	responseBody, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return err
+	}
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return fmt.Errorf("%s %s failed with HTTP status %d: %s", method, path, response.StatusCode, responseBody)
+	}
+
+	if result != nil {
+		return json.Unmarshal(responseBody, result)
+	}
+	return nil
+}
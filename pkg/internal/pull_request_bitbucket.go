@@ -0,0 +1,95 @@
+package internal
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// opens pull requests using the API of Bitbucket Cloud
+type bitbucketProvider struct {
+	client *apiClient
+	// the repository in the form "workspace/repository"
+	repository string
+}
+
+type bitbucketBranch struct {
+	Branch struct {
+		Name string `json:"name"`
+	} `json:"branch"`
+}
+
+type bitbucketPullRequest struct {
+	ID    int `json:"id"`
+	Links struct {
+		Html struct {
+			Href string `json:"href"`
+		} `json:"html"`
+	} `json:"links"`
+	Source      bitbucketBranch `json:"source"`
+	Destination bitbucketBranch `json:"destination"`
+}
+
+func (provider *bitbucketProvider) FindOpenPullRequest(branchPrefix string, targetBranch string) (*PullRequest, error) {
+	for page := 1; ; page++ {
+		var result struct {
+			Values []bitbucketPullRequest `json:"values"`
+			// the URL of the next page, which is missing on the last page
+			Next string `json:"next"`
+		}
+		path := fmt.Sprintf("%s?state=OPEN&pagelen=%d&page=%d", provider.pullRequestsPath(), pullRequestPageSize, page)
+		err := provider.client.do("GET", path, nil, &result)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, pullRequest := range result.Values {
+			if pullRequest.Destination.Branch.Name == targetBranch && strings.HasPrefix(pullRequest.Source.Branch.Name, branchPrefix) {
+				return pullRequest.toPullRequest(), nil
+			}
+		}
+		if result.Next == "" {
+			return nil, nil
+		}
+	}
+}
+
+func (provider *bitbucketProvider) CreatePullRequest(sourceBranch string, targetBranch string, title string, description string) (*PullRequest, error) {
+	body := bitbucketPullRequestBody{Title: title, Description: description}
+	body.Source.Branch.Name = sourceBranch
+	body.Destination.Branch.Name = targetBranch
+
+	var pullRequest bitbucketPullRequest
+	err := provider.client.do("POST", provider.pullRequestsPath(), body, &pullRequest)
+	if err != nil {
+		return nil, err
+	}
+	return pullRequest.toPullRequest(), nil
+}
+
+func (provider *bitbucketProvider) UpdatePullRequest(pullRequest *PullRequest, title string, description string) error {
+	body := map[string]string{
+		"title":       title,
+		"description": description,
+	}
+	return provider.client.do("PUT", provider.pullRequestsPath()+"/"+pullRequest.ID, body, nil)
+}
+
+func (provider *bitbucketProvider) pullRequestsPath() string {
+	return fmt.Sprintf("/repositories/%s/pullrequests", provider.repository)
+}
+
+type bitbucketPullRequestBody struct {
+	Title       string          `json:"title"`
+	Description string          `json:"description"`
+	Source      bitbucketBranch `json:"source"`
+	Destination bitbucketBranch `json:"destination"`
+}
+
+func (pullRequest bitbucketPullRequest) toPullRequest() *PullRequest {
+	return &PullRequest{
+		ID:           strconv.Itoa(pullRequest.ID),
+		URL:          pullRequest.Links.Html.Href,
+		SourceBranch: pullRequest.Source.Branch.Name,
+	}
+}
@@ -0,0 +1,81 @@
+package internal
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// opens pull requests using the API of GitHub, which is also implemented by Gitea and Forgejo
+type githubProvider struct {
+	client     *apiClient
+	repository string
+	// the name of the query parameter limiting the page size, which differs between GitHub and Gitea
+	pageSizeParameter string
+}
+
+type githubPullRequest struct {
+	Number  int    `json:"number"`
+	HtmlUrl string `json:"html_url"`
+	Head    struct {
+		Ref string `json:"ref"`
+	} `json:"head"`
+	Base struct {
+		Ref string `json:"ref"`
+	} `json:"base"`
+}
+
+func (provider *githubProvider) FindOpenPullRequest(branchPrefix string, targetBranch string) (*PullRequest, error) {
+	for page := 1; ; page++ {
+		path := fmt.Sprintf("/repos/%s/pulls?state=open&%s=%d&page=%d&base=%s", provider.repository, provider.pageSizeParameter,
+			pullRequestPageSize, page, url.QueryEscape(targetBranch))
+
+		var pullRequests []githubPullRequest
+		err := provider.client.do("GET", path, nil, &pullRequests)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, pullRequest := range pullRequests {
+			if pullRequest.Base.Ref == targetBranch && strings.HasPrefix(pullRequest.Head.Ref, branchPrefix) {
+				return pullRequest.toPullRequest(), nil
+			}
+		}
+		if len(pullRequests) < pullRequestPageSize {
+			return nil, nil
+		}
+	}
+}
+
+func (provider *githubProvider) CreatePullRequest(sourceBranch string, targetBranch string, title string, description string) (*PullRequest, error) {
+	body := map[string]string{
+		"head":  sourceBranch,
+		"base":  targetBranch,
+		"title": title,
+		"body":  description,
+	}
+
+	var pullRequest githubPullRequest
+	err := provider.client.do("POST", fmt.Sprintf("/repos/%s/pulls", provider.repository), body, &pullRequest)
+	if err != nil {
+		return nil, err
+	}
+	return pullRequest.toPullRequest(), nil
+}
+
+func (provider *githubProvider) UpdatePullRequest(pullRequest *PullRequest, title string, description string) error {
+	body := map[string]string{
+		"title": title,
+		"body":  description,
+	}
+	return provider.client.do("PATCH", fmt.Sprintf("/repos/%s/pulls/%s", provider.repository, pullRequest.ID), body, nil)
+}
+
+func (pullRequest githubPullRequest) toPullRequest() *PullRequest {
+	return &PullRequest{
+		ID:           strconv.Itoa(pullRequest.Number),
+		URL:          pullRequest.HtmlUrl,
+		SourceBranch: pullRequest.Head.Ref,
+	}
+}
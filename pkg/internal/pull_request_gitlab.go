@@ -0,0 +1,80 @@
+package internal
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// opens merge requests using the API of GitLab
+type gitlabProvider struct {
+	client *apiClient
+	// the path or ID of the project
+	project string
+}
+
+type gitlabMergeRequest struct {
+	IID          int    `json:"iid"`
+	WebUrl       string `json:"web_url"`
+	SourceBranch string `json:"source_branch"`
+	TargetBranch string `json:"target_branch"`
+}
+
+func (provider *gitlabProvider) FindOpenPullRequest(branchPrefix string, targetBranch string) (*PullRequest, error) {
+	for page := 1; ; page++ {
+		path := fmt.Sprintf("%s?state=opened&per_page=%d&page=%d&target_branch=%s", provider.mergeRequestsPath(), pullRequestPageSize,
+			page, url.QueryEscape(targetBranch))
+
+		var mergeRequests []gitlabMergeRequest
+		err := provider.client.do("GET", path, nil, &mergeRequests)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, mergeRequest := range mergeRequests {
+			if mergeRequest.TargetBranch == targetBranch && strings.HasPrefix(mergeRequest.SourceBranch, branchPrefix) {
+				return mergeRequest.toPullRequest(), nil
+			}
+		}
+		if len(mergeRequests) < pullRequestPageSize {
+			return nil, nil
+		}
+	}
+}
+
+func (provider *gitlabProvider) CreatePullRequest(sourceBranch string, targetBranch string, title string, description string) (*PullRequest, error) {
+	body := map[string]string{
+		"source_branch": sourceBranch,
+		"target_branch": targetBranch,
+		"title":         title,
+		"description":   description,
+	}
+
+	var mergeRequest gitlabMergeRequest
+	err := provider.client.do("POST", provider.mergeRequestsPath(), body, &mergeRequest)
+	if err != nil {
+		return nil, err
+	}
+	return mergeRequest.toPullRequest(), nil
+}
+
+func (provider *gitlabProvider) UpdatePullRequest(pullRequest *PullRequest, title string, description string) error {
+	body := map[string]string{
+		"title":       title,
+		"description": description,
+	}
+	return provider.client.do("PUT", provider.mergeRequestsPath()+"/"+pullRequest.ID, body, nil)
+}
+
+func (provider *gitlabProvider) mergeRequestsPath() string {
+	return fmt.Sprintf("/projects/%s/merge_requests", url.PathEscape(provider.project))
+}
+
+func (mergeRequest gitlabMergeRequest) toPullRequest() *PullRequest {
+	return &PullRequest{
+		ID:           strconv.Itoa(mergeRequest.IID),
+		URL:          mergeRequest.WebUrl,
+		SourceBranch: mergeRequest.SourceBranch,
+	}
+}
@@ -0,0 +1,359 @@
+package internal
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// a request received by the fake API of a Git hosting service
+type recordedRequest struct {
+	Method  string
+	Path    string
+	Query   map[string]string
+	Headers http.Header
+	Body    map[string]interface{}
+}
+
+// starts a fake API, which records all requests and answers them using the given handler, which returns the status and
+// the JSON response
+func startFakeApi(t *testing.T, handler func(request recordedRequest) (int, interface{})) (*httptest.Server, *[]recordedRequest) {
+	var requests []recordedRequest
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		recorded := recordedRequest{
+			Method:  request.Method,
+			Path:    request.URL.EscapedPath(),
+			Query:   make(map[string]string),
+			Headers: request.Header,
+		}
+		for name := range request.URL.Query() {
+			recorded.Query[name] = request.URL.Query().Get(name)
+		}
+		body, _ := ioutil.ReadAll(request.Body)
+		if len(body) > 0 {
+			if err := json.Unmarshal(body, &recorded.Body); err != nil {
+				t.Errorf("invalid request body %q: %v", body, err)
+			}
+		}
+		requests = append(requests, recorded)
+
+		status, response := handler(recorded)
+		writer.Header().Set("Content-Type", "application/json")
+		writer.WriteHeader(status)
+		json.NewEncoder(writer).Encode(response)
+	}))
+	t.Cleanup(server.Close)
+	return server, &requests
+}
+
+// creates the given provider using the fake API and a token read from a file
+func newTestProvider(t *testing.T, provider string, apiUrl string, repository string) PullRequestProvider {
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	if err := ioutil.WriteFile(tokenFile, []byte("secret-token\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	pullRequestProvider, err := PullRequestConfiguration{
+		Enable:     true,
+		Provider:   provider,
+		ApiUrl:     apiUrl,
+		Repository: repository,
+		TokenFile:  tokenFile,
+	}.provider()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pullRequestProvider
+}
+
+// helper function to check the given field of a request body
+func assertBodyField(t *testing.T, request recordedRequest, field string, expected string) {
+	t.Helper()
+	value := request.Body
+	segments := strings.Split(field, ".")
+	for _, segment := range segments[:len(segments)-1] {
+		value, _ = value[segment].(map[string]interface{})
+	}
+	if actual := value[segments[len(segments)-1]]; actual != expected {
+		t.Errorf("expected %s of %s %s to be %q, got %v", field, request.Method, request.Path, expected, actual)
+	}
+}
+
+func githubPullRequests(count int, offset int) []map[string]interface{} {
+	pullRequests := make([]map[string]interface{}, 0, count)
+	for i := 0; i < count; i++ {
+		pullRequests = append(pullRequests, map[string]interface{}{
+			"number":   offset + i,
+			"html_url": fmt.Sprintf("https://github.example/pulls/%d", offset+i),
+			"head":     map[string]string{"ref": fmt.Sprintf("feature-%d", offset+i)},
+			"base":     map[string]string{"ref": "main"},
+		})
+	}
+	return pullRequests
+}
+
+func TestGithubProvider(t *testing.T) {
+	server, requests := startFakeApi(t, func(request recordedRequest) (int, interface{}) {
+		switch {
+		case request.Method == "GET" && request.Query["page"] == "1":
+			return 200, githubPullRequests(pullRequestPageSize, 1)
+		case request.Method == "GET" && request.Query["page"] == "2":
+			pullRequests := githubPullRequests(1, 100)
+			pullRequests[0]["head"] = map[string]string{"ref": "grafana-dashboard-sync/job/20220101-120000"}
+			return 200, pullRequests
+		case request.Method == "POST":
+			return 201, githubPullRequests(1, 7)[0]
+		default:
+			return 200, map[string]interface{}{}
+		}
+	})
+	provider := newTestProvider(t, "github", server.URL, "owner/repository")
+
+	pullRequest, err := provider.FindOpenPullRequest("grafana-dashboard-sync/job/", "main")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pullRequest == nil || pullRequest.ID != "100" || pullRequest.SourceBranch != "grafana-dashboard-sync/job/20220101-120000" {
+		t.Fatalf("expected the pull request on the second page, got %+v", pullRequest)
+	}
+	if len(*requests) != 2 {
+		t.Fatalf("expected two pages to be requested, got %d requests", len(*requests))
+	}
+	find := (*requests)[0]
+	if find.Path != "/repos/owner/repository/pulls" || find.Query["state"] != "open" || find.Query["base"] != "main" || find.Query["per_page"] != "50" {
+		t.Errorf("unexpected search request %s %v", find.Path, find.Query)
+	}
+	if authorization := find.Headers.Get("Authorization"); authorization != "Bearer secret-token" {
+		t.Errorf("expected bearer authorization, got %q", authorization)
+	}
+
+	created, err := provider.CreatePullRequest("feature", "main", "Title", "Description")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if created.ID != "7" || created.URL != "https://github.example/pulls/7" {
+		t.Errorf("unexpected created pull request %+v", created)
+	}
+	create := (*requests)[2]
+	if create.Method != "POST" || create.Path != "/repos/owner/repository/pulls" {
+		t.Errorf("unexpected create request %s %s", create.Method, create.Path)
+	}
+	assertBodyField(t, create, "head", "feature")
+	assertBodyField(t, create, "base", "main")
+	assertBodyField(t, create, "title", "Title")
+	assertBodyField(t, create, "body", "Description")
+
+	err = provider.UpdatePullRequest(created, "New title", "New description")
+	if err != nil {
+		t.Fatal(err)
+	}
+	update := (*requests)[3]
+	if update.Method != "PATCH" || update.Path != "/repos/owner/repository/pulls/7" {
+		t.Errorf("unexpected update request %s %s", update.Method, update.Path)
+	}
+	assertBodyField(t, update, "title", "New title")
+	assertBodyField(t, update, "body", "New description")
+}
+
+func TestGiteaProvider(t *testing.T) {
+	server, requests := startFakeApi(t, func(request recordedRequest) (int, interface{}) {
+		return 200, githubPullRequests(3, 1)
+	})
+	provider := newTestProvider(t, "gitea", server.URL+"/api/v1/", "owner/repository")
+
+	pullRequest, err := provider.FindOpenPullRequest("grafana-dashboard-sync/job/", "main")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pullRequest != nil {
+		t.Errorf("expected no pull request, got %+v", pullRequest)
+	}
+	if len(*requests) != 1 {
+		t.Fatalf("expected a single page to be requested, got %d requests", len(*requests))
+	}
+	find := (*requests)[0]
+	if find.Path != "/api/v1/repos/owner/repository/pulls" || find.Query["limit"] != "50" || find.Query["page"] != "1" {
+		t.Errorf("unexpected search request %s %v", find.Path, find.Query)
+	}
+	if authorization := find.Headers.Get("Authorization"); authorization != "token secret-token" {
+		t.Errorf("expected token authorization, got %q", authorization)
+	}
+
+	_, err = PullRequestConfiguration{Enable: true, Provider: "gitea"}.provider()
+	if err == nil {
+		t.Error("expected an error, because Gitea requires an API URL")
+	}
+}
+
+func TestGitlabProvider(t *testing.T) {
+	server, requests := startFakeApi(t, func(request recordedRequest) (int, interface{}) {
+		switch request.Method {
+		case "GET":
+			return 200, []map[string]interface{}{
+				{"iid": 3, "web_url": "https://gitlab.example/mr/3", "source_branch": "other", "target_branch": "main"},
+				{"iid": 4, "web_url": "https://gitlab.example/mr/4", "source_branch": "grafana-dashboard-sync/job/1", "target_branch": "main"},
+			}
+		case "POST":
+			return 201, map[string]interface{}{"iid": 5, "web_url": "https://gitlab.example/mr/5", "source_branch": "feature", "target_branch": "main"}
+		default:
+			return 200, map[string]interface{}{}
+		}
+	})
+	provider := newTestProvider(t, "gitlab", server.URL, "group/repository")
+
+	pullRequest, err := provider.FindOpenPullRequest("grafana-dashboard-sync/job/", "main")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pullRequest == nil || pullRequest.ID != "4" || pullRequest.URL != "https://gitlab.example/mr/4" {
+		t.Fatalf("expected merge request 4, got %+v", pullRequest)
+	}
+	find := (*requests)[0]
+	if find.Path != "/projects/group%2Frepository/merge_requests" || find.Query["state"] != "opened" || find.Query["target_branch"] != "main" {
+		t.Errorf("unexpected search request %s %v", find.Path, find.Query)
+	}
+	if token := find.Headers.Get("PRIVATE-TOKEN"); token != "secret-token" {
+		t.Errorf("expected private token header, got %q", token)
+	}
+	if authorization := find.Headers.Get("Authorization"); authorization != "" {
+		t.Errorf("expected no authorization header, got %q", authorization)
+	}
+
+	created, err := provider.CreatePullRequest("feature", "main", "Title", "Description")
+	if err != nil {
+		t.Fatal(err)
+	}
+	create := (*requests)[1]
+	if created.ID != "5" || create.Method != "POST" || create.Path != "/projects/group%2Frepository/merge_requests" {
+		t.Errorf("unexpected create request %s %s", create.Method, create.Path)
+	}
+	assertBodyField(t, create, "source_branch", "feature")
+	assertBodyField(t, create, "target_branch", "main")
+	assertBodyField(t, create, "title", "Title")
+	assertBodyField(t, create, "description", "Description")
+
+	err = provider.UpdatePullRequest(created, "New title", "New description")
+	if err != nil {
+		t.Fatal(err)
+	}
+	update := (*requests)[2]
+	if update.Method != "PUT" || update.Path != "/projects/group%2Frepository/merge_requests/5" {
+		t.Errorf("unexpected update request %s %s", update.Method, update.Path)
+	}
+	assertBodyField(t, update, "title", "New title")
+	assertBodyField(t, update, "description", "New description")
+}
+
+func bitbucketPullRequestResponse(id int, source string) map[string]interface{} {
+	return map[string]interface{}{
+		"id":          id,
+		"links":       map[string]interface{}{"html": map[string]string{"href": fmt.Sprintf("https://bitbucket.example/pr/%d", id)}},
+		"source":      map[string]interface{}{"branch": map[string]string{"name": source}},
+		"destination": map[string]interface{}{"branch": map[string]string{"name": "main"}},
+	}
+}
+
+func TestBitbucketProvider(t *testing.T) {
+	server, requests := startFakeApi(t, func(request recordedRequest) (int, interface{}) {
+		switch {
+		case request.Method == "GET" && request.Query["page"] == "1":
+			return 200, map[string]interface{}{
+				"values": []interface{}{bitbucketPullRequestResponse(1, "other")},
+				"next":   "https://api.bitbucket.example/next",
+			}
+		case request.Method == "GET":
+			return 200, map[string]interface{}{
+				"values": []interface{}{bitbucketPullRequestResponse(2, "grafana-dashboard-sync/job/1")},
+			}
+		case request.Method == "POST":
+			return 201, bitbucketPullRequestResponse(3, "feature")
+		default:
+			return 200, map[string]interface{}{}
+		}
+	})
+	provider := newTestProvider(t, "bitbucket", server.URL, "workspace/repository")
+
+	pullRequest, err := provider.FindOpenPullRequest("grafana-dashboard-sync/job/", "main")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pullRequest == nil || pullRequest.ID != "2" || pullRequest.URL != "https://bitbucket.example/pr/2" {
+		t.Fatalf("expected the pull request on the second page, got %+v", pullRequest)
+	}
+	if len(*requests) != 2 || (*requests)[1].Query["page"] != "2" {
+		t.Fatalf("expected two pages to be requested, got %+v", *requests)
+	}
+	find := (*requests)[0]
+	if find.Path != "/repositories/workspace/repository/pullrequests" || find.Query["state"] != "OPEN" {
+		t.Errorf("unexpected search request %s %v", find.Path, find.Query)
+	}
+	if authorization := find.Headers.Get("Authorization"); authorization != "Bearer secret-token" {
+		t.Errorf("expected bearer authorization, got %q", authorization)
+	}
+
+	created, err := provider.CreatePullRequest("feature", "main", "Title", "Description")
+	if err != nil {
+		t.Fatal(err)
+	}
+	create := (*requests)[2]
+	if created.ID != "3" || create.Method != "POST" || create.Path != "/repositories/workspace/repository/pullrequests" {
+		t.Errorf("unexpected create request %s %s", create.Method, create.Path)
+	}
+	assertBodyField(t, create, "source.branch.name", "feature")
+	assertBodyField(t, create, "destination.branch.name", "main")
+	assertBodyField(t, create, "title", "Title")
+	assertBodyField(t, create, "description", "Description")
+
+	err = provider.UpdatePullRequest(created, "New title", "New description")
+	if err != nil {
+		t.Fatal(err)
+	}
+	update := (*requests)[3]
+	if update.Method != "PUT" || update.Path != "/repositories/workspace/repository/pullrequests/3" {
+		t.Errorf("unexpected update request %s %s", update.Method, update.Path)
+	}
+	assertBodyField(t, update, "title", "New title")
+	assertBodyField(t, update, "description", "New description")
+}
+
+func TestPullRequestProviderErrors(t *testing.T) {
+	server, _ := startFakeApi(t, func(request recordedRequest) (int, interface{}) {
+		return 422, map[string]string{"message": "Validation Failed"}
+	})
+
+	for _, name := range []string{"github", "gitea", "gitlab", "bitbucket"} {
+		provider := newTestProvider(t, name, server.URL, "owner/repository")
+
+		_, err := provider.FindOpenPullRequest("prefix/", "main")
+		if err == nil || !strings.Contains(err.Error(), "422") {
+			t.Errorf("%s: expected the HTTP status of the failed search, got %v", name, err)
+		}
+		_, err = provider.CreatePullRequest("feature", "main", "Title", "Description")
+		if err == nil || !strings.Contains(err.Error(), "Validation Failed") {
+			t.Errorf("%s: expected the response of the failed creation, got %v", name, err)
+		}
+		err = provider.UpdatePullRequest(&PullRequest{ID: "1"}, "Title", "Description")
+		if err == nil {
+			t.Errorf("%s: expected the update to fail", name)
+		}
+	}
+}
+
+func TestDashboardsOfFiles(t *testing.T) {
+	dashboards := dashboardsOfFiles([]string{
+		"Folder/Dashboard.json",
+		"Folder/.folder.json",
+		"Folder/Exploded/dashboard.json",
+		"Folder/Exploded/panels/1.json",
+		".grafana-dashboard-sync-state.json",
+	})
+	expected := []string{"Folder/Dashboard.json", "Folder/Exploded"}
+	if strings.Join(dashboards, ",") != strings.Join(expected, ",") {
+		t.Errorf("expected %v, got %v", expected, dashboards)
+	}
+}
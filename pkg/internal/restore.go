@@ -0,0 +1,148 @@
+package internal
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Restore imports a single dashboard into Grafana with the content it had at the given revision, which is a commit or
+// a tag of the job's pull branch, or its push branch in case pulling is disabled. The dashboard is identified either by
+// its UID or by its path in the repository, e.g. "Folder/Dashboard.json".
+func (s *Synchronization) Restore(dashboardId string, revision string, dryRun bool) error {
+	branch := s.options.PullConfiguration.GitBranch
+	if !s.options.PullConfiguration.Enable {
+		branch = s.options.PushConfiguration.GitBranch
+	}
+
+	log.WithFields(log.Fields{
+		"job":           s.options.JobName,
+		"target-branch": branch,
+		"dashboard":     dashboardId,
+		"revision":      revision,
+	}).Info("Starting restore of dashboard from the Git repository.")
+
+	if branch == "" || revision == "" {
+		err := errors.New("the job's branch and the revision to restore are required")
+		log.WithFields(log.Fields{
+			"error": err,
+			"job":   s.options.JobName,
+		}).Fatal("Invalid restore configuration.")
+		return err
+	}
+
+	// the history of the branch is required to check out any of its commits
+	s.gitApi.RequireHistory()
+	defer s.gitApi.Close()
+
+	_, err := s.gitApi.CloneRepo(branch)
+	if err != nil {
+		log.WithField("error", err).Fatal("Error while cloning repository.")
+		return err
+	}
+	commitHash, err := s.gitApi.CheckoutRevision(revision)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":    err,
+			"revision": revision,
+		}).Fatal("Error while checking out the revision to restore.")
+		return err
+	}
+
+	dashboardPath, dashboardJson, err := s.findDashboard(dashboardId)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":     err,
+			"dashboard": dashboardId,
+		}).Fatal("Failed to read the dashboard to restore.")
+		return err
+	} else if dashboardJson == nil {
+		err = fmt.Errorf("dashboard %q does not exist at revision '%s'", dashboardId, revision)
+		log.WithField("error", err).Fatal("Dashboard to restore not found.")
+		return err
+	}
+
+	dashboard := DashboardWithCustomFields{}
+	err = json.Unmarshal(dashboardJson, &dashboard)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"dashboard": dashboardPath,
+			"error":     err,
+		}).Fatal("Failed to unmarshal dashboard.")
+		return err
+	}
+
+	// get Grafana folder or create it if it doesn't exist
+	folderName := path.Dir(dashboardPath)
+	folder, err := s.grafanaApi.GetFolder(folderName)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"folder": folderName,
+			"error":  err,
+		}).Fatal("Could not fetch Grafana folder.")
+		return err
+	}
+	if folder == nil {
+		log.WithField("folder", folderName).Info("Creating Grafana folder.")
+		if !dryRun {
+			folder, err = s.grafanaApi.CreateFolder(folderName, s.folderUID(folderName))
+			if err != nil {
+				log.WithFields(log.Fields{
+					"folder": folderName,
+					"error":  err,
+				}).Fatal("Could not create Grafana folder.")
+				return err
+			}
+		}
+	}
+
+	// the restore is not marked as synchronized version, so it is treated as a change made in Grafana by later imports
+	versionMessage := fmt.Sprintf("Restored dashboard from commit %s.", commitHash)
+	if revision != commitHash {
+		versionMessage = fmt.Sprintf("Restored dashboard from commit %s of revision '%s'.", commitHash, revision)
+	}
+
+	log.WithFields(log.Fields{
+		"dashboard": dashboard.Title,
+		"uid":       dashboard.UID,
+		"folder":    folderName,
+		"commit":    commitHash,
+	}).Info("Importing dashboard into Grafana.")
+	if !dryRun {
+		s.grafanaApi.CreateOrUpdateDashboardObjectByID(dashboardJson, folder.ID, versionMessage)
+	}
+
+	log.WithField("dashboard", dashboardPath).Info("Successfully restored dashboard from Git repository.")
+	return nil
+}
+
+// Returns the path and JSON of the dashboard with the given path or UID in the worktree. The extension of a dashboard
+// file can be omitted from its path. Returns a nil JSON in case the dashboard does not exist.
+func (s *Synchronization) findDashboard(dashboardId string) (string, []byte, error) {
+	jsonnetConfiguration := s.options.PullConfiguration.Jsonnet
+
+	if strings.Contains(dashboardId, "/") {
+		dashboardPath := strings.TrimSuffix(dashboardId, "/")
+		for _, candidate := range append([]string{""}, dashboardFileExtensions...) {
+			dashboardJson, err := s.gitApi.ReadDashboard(dashboardPath+candidate, jsonnetConfiguration)
+			if err != nil || dashboardJson != nil {
+				return dashboardPath + candidate, dashboardJson, err
+			}
+		}
+		return dashboardPath, nil, nil
+	}
+
+	for folderName, dashboardFiles := range s.gitApi.GetFileContent(jsonnetConfiguration) {
+		for fileName, dashboardJson := range dashboardFiles {
+			dashboard := DashboardWithCustomFields{}
+			if json.Unmarshal(dashboardJson, &dashboard) == nil && dashboard.UID == dashboardId {
+				return folderName + "/" + strings.TrimSuffix(fileName, "/"), dashboardJson, nil
+			}
+		}
+	}
+	return "", nil, nil
+}
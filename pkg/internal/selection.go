@@ -0,0 +1,180 @@
+package internal
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// modes how the tags of a selection are combined
+const (
+	tagMatchAny = "any"
+	tagMatchAll = "all"
+)
+
+// SelectionConfiguration restricts the synchronized dashboards in addition to the filter. All configured criteria have
+// to match, criteria which are not configured select all dashboards.
+type SelectionConfiguration struct {
+	// the tags of the dashboards, which are required in addition to the sync tag of the push configuration
+	Tags []string `yaml:"tags"`
+	// whether the dashboards need to have "any" (default) or "all" of the tags
+	TagMatch string `yaml:"tag-match"`
+	// the titles of the folders containing the dashboards
+	Folders []string `yaml:"folders"`
+	// the UIDs of the dashboards
+	UIDs []string `yaml:"uids"`
+	// whether only dashboards starred by the user of the Grafana token are selected
+	Starred bool `yaml:"starred"`
+	// dashboards whose path "<folder>/<title>" matches this pattern are excluded
+	Exclude string `yaml:"exclude"`
+}
+
+// the parsed selection of dashboards
+type dashboardSelection struct {
+	configuration SelectionConfiguration
+	// the sync tag, which is required regardless of the other tags
+	syncTag  *tagPattern
+	tags     []*tagPattern
+	matchAll bool
+	folders  map[string]bool
+	uids     map[string]bool
+	starred  bool
+	exclude  *regexp.Regexp
+}
+
+// Parses the given selection configuration. The given sync tag pattern, if not nil, is required in addition to the
+// selected tags, which are matched according to the configured tag match.
+func newDashboardSelection(configuration SelectionConfiguration, syncPattern *tagPattern) (*dashboardSelection, error) {
+	selection := dashboardSelection{configuration: configuration, syncTag: syncPattern, starred: configuration.Starred}
+
+	for _, tag := range configuration.Tags {
+		selection.tags = append(selection.tags, &tagPattern{pattern: tag, syntax: tagPatternSyntaxExact})
+	}
+
+	switch configuration.TagMatch {
+	case "", tagMatchAny:
+	case tagMatchAll:
+		selection.matchAll = true
+	default:
+		return nil, fmt.Errorf("invalid tag match %q, must be '%s' or '%s'", configuration.TagMatch, tagMatchAny, tagMatchAll)
+	}
+
+	if len(configuration.Folders) > 0 {
+		selection.folders = make(map[string]bool)
+		for _, folder := range configuration.Folders {
+			selection.folders[folder] = true
+		}
+	}
+	if len(configuration.UIDs) > 0 {
+		selection.uids = make(map[string]bool)
+		for _, uid := range configuration.UIDs {
+			selection.uids[uid] = true
+		}
+	}
+
+	if configuration.Exclude != "" {
+		exclude, err := regexp.Compile(configuration.Exclude)
+		if err != nil {
+			return nil, fmt.Errorf("invalid exclude pattern: %w", err)
+		}
+		selection.exclude = exclude
+	}
+	return &selection, nil
+}
+
+// Returns the tags which can be passed to the Grafana search, which requires dashboards to have all given tags.
+// Tag patterns are matched by the selection only, which requires searching all dashboards.
+func (selection *dashboardSelection) searchTags() []string {
+	var tags []string
+	if selection.syncTag != nil && selection.syncTag.isExact() {
+		tags = append(tags, selection.syncTag.pattern)
+	}
+	if selection.matchAll || len(selection.tags) == 1 {
+		for _, tag := range selection.tags {
+			tags = append(tags, tag.pattern)
+		}
+	}
+	return tags
+}
+
+// Checks whether the given dashboard is selected. Returns the reason in case it is not.
+func (selection *dashboardSelection) matches(uid string, folder string, title string, tags []string, starred bool) (bool, string) {
+	if selection.uids != nil && !selection.uids[uid] {
+		return false, "its UID is not selected"
+	}
+	if selection.folders != nil && !selection.folders[folder] {
+		return false, "its folder is not selected"
+	}
+	if selection.starred && !starred {
+		return false, "it is not starred"
+	}
+	if selection.syncTag != nil && !matchesAnyTag(selection.syncTag, tags) {
+		return false, "it does not have the sync tag"
+	}
+	if len(selection.tags) > 0 && !selection.matchesTags(tags) {
+		return false, "its tags are not selected"
+	}
+	if selection.exclude != nil && selection.exclude.MatchString(folder+"/"+title) {
+		return false, "it matches the exclude pattern"
+	}
+	return true, ""
+}
+
+// helper function to check whether the given tags match any or all of the selected tags
+func (selection *dashboardSelection) matchesTags(tags []string) bool {
+	for _, pattern := range selection.tags {
+		present := matchesAnyTag(pattern, tags)
+		if present && !selection.matchAll {
+			return true
+		} else if !present && selection.matchAll {
+			return false
+		}
+	}
+	return selection.matchAll
+}
+
+// helper function to check whether any of the given tags matches the given pattern
+func matchesAnyTag(pattern *tagPattern, tags []string) bool {
+	for _, tag := range tags {
+		if pattern.matches(tag) {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns a description of the selection, which is reported in the summary of the synchronization.
+func (selection *dashboardSelection) String() string {
+	var criteria []string
+	if selection.syncTag != nil {
+		criteria = append(criteria, fmt.Sprintf("sync tag %s", selection.syncTag.String()))
+	}
+	if len(selection.tags) > 0 {
+		match := tagMatchAny
+		if selection.matchAll {
+			match = tagMatchAll
+		}
+		tags := make([]string, 0, len(selection.tags))
+		for _, tag := range selection.tags {
+			tags = append(tags, tag.String())
+		}
+		criteria = append(criteria, fmt.Sprintf("%s of tags [%s]", match, strings.Join(tags, ", ")))
+	}
+	if selection.folders != nil {
+		criteria = append(criteria, fmt.Sprintf("folders [%s]", strings.Join(selection.configuration.Folders, ", ")))
+	}
+	if selection.uids != nil {
+		criteria = append(criteria, fmt.Sprintf("UIDs [%s]", strings.Join(selection.configuration.UIDs, ", ")))
+	}
+	if selection.starred {
+		criteria = append(criteria, "starred")
+	}
+	if selection.exclude != nil {
+		criteria = append(criteria, fmt.Sprintf("excluding %q", selection.exclude.String()))
+	}
+
+	if len(criteria) == 0 {
+		return "all dashboards"
+	}
+	return strings.Join(criteria, ", ")
+}
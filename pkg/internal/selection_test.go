@@ -0,0 +1,61 @@
+package internal
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSelectionRequiresSyncTag(t *testing.T) {
+	syncPattern, err := newTagPattern("sync", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	selection, err := newDashboardSelection(SelectionConfiguration{Tags: []string{"team-a", "team-b"}}, syncPattern)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		tags     []string
+		selected bool
+	}{
+		{[]string{"sync", "team-a"}, true},
+		{[]string{"sync", "team-b"}, true},
+		{[]string{"team-a", "team-b"}, false},
+		{[]string{"sync"}, false},
+		{[]string{"sync", "team-c"}, false},
+	}
+	for _, test := range tests {
+		if selected, _ := selection.matches("uid", "Folder", "Dashboard", test.tags, false); selected != test.selected {
+			t.Errorf("expected selection of tags %v to be %v", test.tags, test.selected)
+		}
+	}
+
+	if tags := selection.searchTags(); strings.Join(tags, ",") != "sync" {
+		t.Errorf("expected only the sync tag to be searched, got %v", tags)
+	}
+}
+
+func TestSelectionMatchesAllTags(t *testing.T) {
+	syncPattern, err := newTagPattern("sync-*", "glob")
+	if err != nil {
+		t.Fatal(err)
+	}
+	selection, err := newDashboardSelection(SelectionConfiguration{Tags: []string{"team-a", "prod"}, TagMatch: "all"}, syncPattern)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if selected, _ := selection.matches("uid", "Folder", "Dashboard", []string{"sync-dev", "team-a", "prod"}, false); !selected {
+		t.Error("expected dashboard with sync tag and all tags to be selected")
+	}
+	if selected, _ := selection.matches("uid", "Folder", "Dashboard", []string{"sync-dev", "team-a"}, false); selected {
+		t.Error("expected dashboard missing a tag not to be selected")
+	}
+	if selected, _ := selection.matches("uid", "Folder", "Dashboard", []string{"team-a", "prod"}, false); selected {
+		t.Error("expected dashboard without sync tag not to be selected")
+	}
+	if tags := selection.searchTags(); strings.Join(tags, ",") != "team-a,prod" {
+		t.Errorf("expected the selected tags to be searched, got %v", tags)
+	}
+}
@@ -0,0 +1,181 @@
+package internal
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha512"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+	"golang.org/x/crypto/ssh"
+)
+
+// namespace of SSH signatures created for Git objects
+const sshSignatureNamespace = "git"
+
+type SigningConfiguration struct {
+	// the format of the signing key, either "openpgp" or "ssh". Commits are not signed if empty
+	Format string `yaml:"format"`
+	// file or environment variable containing the private key, an armored OpenPGP key or a private SSH key
+	KeyFile string `yaml:"key-file"`
+	KeyEnv  string `yaml:"key-env"`
+	// file or environment variable containing the passphrase of the private key, if it is encrypted
+	PassphraseFile string `yaml:"passphrase-file"`
+	PassphraseEnv  string `yaml:"passphrase-env"`
+}
+
+// CommitSigner creates signatures for commits
+type CommitSigner interface {
+	// Sign returns the armored signature of the given encoded commit
+	Sign(message []byte) (string, error)
+}
+
+// Loads the configured signing key. Returns nil in case commits should not be signed.
+func (configuration SigningConfiguration) signer() (CommitSigner, error) {
+	if configuration.Format == "" {
+		return nil, nil
+	}
+
+	key, err := readSecret(configuration.KeyFile, configuration.KeyEnv)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read signing key: %v", err)
+	} else if len(key) == 0 {
+		return nil, errors.New("signing key must not be empty")
+	}
+	passphrase, err := readSecret(configuration.PassphraseFile, configuration.PassphraseEnv)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read passphrase of signing key: %v", err)
+	}
+	passphrase = bytes.TrimRight(passphrase, "\r\n")
+
+	switch configuration.Format {
+	case "openpgp":
+		return newOpenPGPSigner(key, passphrase)
+	case "ssh":
+		return newSSHSigner(key, passphrase)
+	default:
+		return nil, fmt.Errorf("invalid signing format %q, must be 'openpgp' or 'ssh'", configuration.Format)
+	}
+}
+
+// helper function to read a secret from the given file or, if no file is given, from the given environment variable
+func readSecret(file string, env string) ([]byte, error) {
+	if file != "" {
+		return ioutil.ReadFile(file)
+	}
+	if env != "" {
+		return []byte(os.Getenv(env)), nil
+	}
+	return nil, nil
+}
+
+// signs commits using an OpenPGP key
+type openPGPSigner struct {
+	entity *openpgp.Entity
+	// the ID of the key used for signing, which is either a signing subkey or the primary key
+	keyId uint64
+}
+
+// Creates a signer using the first key of the given key ring which is able to sign. Like git, the most recent valid signing
+// subkey is used or, if there is none, the primary key.
+func newOpenPGPSigner(key []byte, passphrase []byte) (*openPGPSigner, error) {
+	entities, err := openpgp.ReadArmoredKeyRing(bytes.NewReader(key))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, entity := range entities {
+		signingKey, ok := entity.SigningKey(time.Now())
+		if !ok || signingKey.PrivateKey == nil {
+			continue
+		}
+
+		if signingKey.PrivateKey.Encrypted {
+			err = signingKey.PrivateKey.Decrypt(passphrase)
+			if err != nil {
+				return nil, fmt.Errorf("cannot decrypt OpenPGP key: %v", err)
+			}
+		}
+		return &openPGPSigner{entity, signingKey.PrivateKey.KeyId}, nil
+	}
+
+	return nil, errors.New("no OpenPGP private key found, which is able to sign")
+}
+
+func (signer *openPGPSigner) Sign(message []byte) (string, error) {
+	var signature bytes.Buffer
+	err := openpgp.ArmoredDetachSign(&signature, signer.entity, bytes.NewReader(message), &packet.Config{SigningKeyId: signer.keyId})
+	if err != nil {
+		return "", err
+	}
+	return signature.String(), nil
+}
+
+// signs commits using an SSH key, see https://github.com/openssh/openssh-portable/blob/master/PROTOCOL.sshsig
+type sshSigner struct {
+	signer ssh.Signer
+}
+
+func newSSHSigner(key []byte, passphrase []byte) (*sshSigner, error) {
+	var signer ssh.Signer
+	var err error
+	if len(passphrase) > 0 {
+		signer, err = ssh.ParsePrivateKeyWithPassphrase(key, passphrase)
+	} else {
+		signer, err = ssh.ParsePrivateKey(key)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &sshSigner{signer}, nil
+}
+
+func (signer *sshSigner) Sign(message []byte) (string, error) {
+	hash := sha512.Sum512(message)
+	signedData := ssh.Marshal(struct {
+		Namespace     string
+		Reserved      string
+		HashAlgorithm string
+		Hash          []byte
+	}{sshSignatureNamespace, "", "sha512", hash[:]})
+	signedData = append([]byte("SSHSIG"), signedData...)
+
+	var signature *ssh.Signature
+	var err error
+	if algorithmSigner, ok := signer.signer.(ssh.AlgorithmSigner); ok && signer.signer.PublicKey().Type() == ssh.KeyAlgoRSA {
+		// SHA-1 based RSA signatures are not accepted for SSH signatures
+		signature, err = algorithmSigner.SignWithAlgorithm(rand.Reader, signedData, ssh.SigAlgoRSASHA2512)
+	} else {
+		signature, err = signer.signer.Sign(rand.Reader, signedData)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	blob := ssh.Marshal(struct {
+		Version       uint32
+		PublicKey     []byte
+		Namespace     string
+		Reserved      string
+		HashAlgorithm string
+		Signature     []byte
+	}{1, signer.signer.PublicKey().Marshal(), sshSignatureNamespace, "", "sha512", ssh.Marshal(signature)})
+	encoded := base64.StdEncoding.EncodeToString(append([]byte("SSHSIG"), blob...))
+
+	var armored strings.Builder
+	armored.WriteString("-----BEGIN SSH SIGNATURE-----\n")
+	for len(encoded) > 70 {
+		armored.WriteString(encoded[:70] + "\n")
+		encoded = encoded[70:]
+	}
+	armored.WriteString(encoded + "\n")
+	armored.WriteString("-----END SSH SIGNATURE-----\n")
+	return armored.String(), nil
+}
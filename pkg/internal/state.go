@@ -0,0 +1,199 @@
+package internal
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// the paths of the state file, used in case no path is configured
+const defaultLocalStatePath = "grafana-dashboard-sync-state.json"
+const defaultRepositoryStatePath = ".grafana-dashboard-sync-state.json"
+
+// how long to wait for another job writing the local state file
+const stateLockTimeout = 1 * time.Minute
+
+type StateConfiguration struct {
+	// where the state of the last synchronization is stored, either "local" for a file on the local filesystem or
+	// "repository" for a file committed into the push branch. No state is stored if empty
+	Storage string `yaml:"storage"`
+	// the path of the state file on the local filesystem or in the repository
+	Path string `yaml:"path"`
+}
+
+// SyncState is the state of the last synchronization of all jobs sharing a state file
+type SyncState struct {
+	Jobs map[string]*JobState `json:"jobs"`
+}
+
+// JobState is the state of the last synchronization of a job, mapping the dashboards' UIDs to their state
+type JobState struct {
+	Push map[string]DashboardState `json:"push"`
+	Pull map[string]DashboardState `json:"pull"`
+}
+
+// DashboardState is the state of a dashboard after its last synchronization
+type DashboardState struct {
+	UID string `json:"uid"`
+	// the version of the dashboard in Grafana after the synchronization
+	GrafanaVersion uint `json:"grafanaVersion"`
+	// the hash of the synchronized content, see contentHash
+	ContentHash string `json:"contentHash"`
+	// the commit the dashboard has been exported into or imported from
+	Commit string `json:"commit"`
+	// the paths of the exported files or of the imported file or directory, which are removed once the dashboard is
+	// exported into other files, e.g. because it has been renamed
+	Files []string `json:"files,omitempty"`
+}
+
+func newJobState() *JobState {
+	return &JobState{
+		Push: make(map[string]DashboardState),
+		Pull: make(map[string]DashboardState),
+	}
+}
+
+// Validates the configured storage, which requires pushing into the repository in case the state is stored in it.
+func (configuration StateConfiguration) validate(push PushConfiguration) error {
+	switch configuration.Storage {
+	case "", "local":
+		return nil
+	case "repository":
+		if !push.Enable {
+			return errors.New("storing the state in the repository requires the push configuration to be enabled")
+		} else if push.PullRequest.Enable {
+			return errors.New("storing the state in the repository cannot be combined with pull requests")
+		}
+		return nil
+	default:
+		return fmt.Errorf("invalid state storage %q, must be 'local' or 'repository'", configuration.Storage)
+	}
+}
+
+// Returns the configured path of the state file or the default path of the configured storage.
+func (configuration StateConfiguration) path() string {
+	if configuration.Path != "" {
+		return configuration.Path
+	} else if configuration.Storage == "repository" {
+		return defaultRepositoryStatePath
+	}
+	return defaultLocalStatePath
+}
+
+// Parses the given content of a state file and returns the state of the given job. An empty state is returned for empty content.
+func parseJobState(content []byte, jobName string) (*JobState, error) {
+	state, err := parseState(content)
+	if err != nil {
+		return nil, err
+	}
+
+	jobState, ok := state.Jobs[jobName]
+	if !ok || jobState == nil {
+		return newJobState(), nil
+	}
+	if jobState.Push == nil {
+		jobState.Push = make(map[string]DashboardState)
+	}
+	if jobState.Pull == nil {
+		jobState.Pull = make(map[string]DashboardState)
+	}
+	return jobState, nil
+}
+
+// helper function to parse the given content of a state file
+func parseState(content []byte) (*SyncState, error) {
+	state := &SyncState{}
+	if len(content) > 0 {
+		err := json.Unmarshal(content, state)
+		if err != nil {
+			return nil, fmt.Errorf("invalid state file: %v", err)
+		}
+	}
+	if state.Jobs == nil {
+		state.Jobs = make(map[string]*JobState)
+	}
+	return state, nil
+}
+
+// Replaces the state of the given job in the given content of a state file and returns the updated content.
+// The states of other jobs are kept.
+func updateJobState(content []byte, jobName string, jobState *JobState) ([]byte, error) {
+	state, err := parseState(content)
+	if err != nil {
+		return nil, err
+	}
+	state.Jobs[jobName] = jobState
+
+	// maps are encoded with sorted keys, so the file only changes if the state changes
+	updated, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(updated, '\n'), nil
+}
+
+// Reads the state of the given job from the given file on the local filesystem.
+func loadLocalJobState(path string, jobName string) (*JobState, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	return parseJobState(content, jobName)
+}
+
+// Writes the state of the given job into the given file on the local filesystem. The file is locked while it is
+// updated, so jobs sharing the file do not overwrite each other's state.
+func saveLocalJobState(path string, jobName string, jobState *JobState) error {
+	lock, err := AcquireFileLock(path+".lock", stateLockTimeout)
+	if err != nil {
+		return err
+	}
+	defer lock.Release()
+
+	content, err := ioutil.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	updated, err := updateJobState(content, jobName, jobState)
+	if err != nil {
+		return err
+	}
+
+	// write a temporary file first, so the state file is never left incomplete
+	temporaryFile, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	_, err = temporaryFile.Write(updated)
+	if closeErr := temporaryFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(temporaryFile.Name())
+		return err
+	}
+	return os.Rename(temporaryFile.Name(), path)
+}
+
+// helper function to return the hash of the given exported files, see contentHash
+func exportFilesHash(files map[string][]byte) string {
+	paths := make([]string, 0, len(files))
+	for path := range files {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	hash := sha256.New()
+	for _, path := range paths {
+		fmt.Fprintf(hash, "%s\x00%d\x00", path, len(files[path]))
+		hash.Write(files[path])
+	}
+	return hex.EncodeToString(hash.Sum(nil))[:16]
+}
@@ -1,38 +1,106 @@
 package internal
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"reflect"
+	"os"
 	"regexp"
+	"sort"
 	"strconv"
+	"strings"
+	"text/template"
+	"time"
 
-	sdk "github.com/NovatecConsulting/grafana-api-go-sdk"
 	log "github.com/sirupsen/logrus"
+	"gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
 )
 
+// the number of retries of a rejected push, used in case no number is configured
+const defaultPushRetries = 3
+
 type SynchronizeOptions struct {
 	JobName      string `yaml:"job-name"`
 	GrafanaToken string `yaml:"grafana-token"`
 	GrafanaUrl   string `yaml:"grafana-url"`
 
-	GitRepositoryUrl string `yaml:"git-repository-url"`
-	PrivateKeyFile   string `yaml:"private-key-file"`
+	GitRepositoryUrl  string `yaml:"git-repository-url"`
+	PrivateKeyFile    string `yaml:"private-key-file"`
+	GitCacheDirectory string `yaml:"git-cache-directory"`
+	// fields removed from exported dashboards and ignored when comparing dashboards, as they differ between Grafana instances
+	VolatileFields []string `yaml:"volatile-fields"`
+	// where the state of the last synchronization is stored, used to skip unchanged dashboards
+	SyncState StateConfiguration `yaml:"sync-state"`
+	// whether dashboards are pushed and pulled on a single branch, so changes flow in both directions
+	Bidirectional bool `yaml:"bidirectional"`
 
 	PushConfiguration PushConfiguration `yaml:"push-configuration"`
 	PullConfiguration PullConfiguration `yaml:"pull-configuration"`
 }
 
-type PullConfiguration struct {
+type SyncConfiguration struct {
 	Enable    bool   `yaml:"enable"`
 	GitBranch string `yaml:"git-branch"`
 	Filter    string `yaml:"filter"`
+	// further criteria restricting the synchronized dashboards
+	Selection SelectionConfiguration `yaml:"selection"`
+}
+
+type PullConfiguration struct {
+	SyncConfiguration `yaml:",inline"`
+	// tag or full commit hash to import the dashboards from instead of the head of the branch
+	GitRevision string `yaml:"git-revision"`
+	// semantic version constraint to import the dashboards from the highest matching tag
+	GitTagRange string `yaml:"git-tag-range"`
+	// library paths and external variables used to evaluate Jsonnet files
+	Jsonnet JsonnetConfiguration `yaml:"jsonnet"`
+	// how dashboards are handled which have been changed both in Git and in Grafana since their last import:
+	// "git-wins" (default), "grafana-wins", "skip-and-report" or "fail"
+	ConflictPolicy string `yaml:"conflict-policy"`
 }
 
 type PushConfiguration struct {
-	PullConfiguration `yaml:",inline"`
+	SyncConfiguration `yaml:",inline"`
 	TagPattern        string `yaml:"tag-pattern"`
 	PushTags          bool   `yaml:"push-tags"`
+	// the syntax of the tag pattern: "exact" (default), "glob" or "regex"
+	TagPatternSyntax string `yaml:"tag-pattern-syntax"`
+	// when the sync tag, which is not pushed, is removed in Grafana: "always" (default), "export-only" to remove it
+	// only from the exported files or "on-change" to remove it only from dashboards changed by the export
+	TagRemoval string `yaml:"tag-removal"`
+	// number of spaces used to indent the exported dashboard files
+	JsonIndent int `yaml:"json-indent"`
+	// the format of the exported dashboard files, either "json" (default) or "yaml"
+	FileFormat string `yaml:"file-format"`
+	// the layout of the exported dashboards, either "file" (default) for a single file per dashboard or "exploded"
+	// for a directory per dashboard containing a metadata file, one file per panel or row and a templating file
+	Layout string `yaml:"layout"`
+	// pushes the dashboards into a new branch and opens a pull request into the push branch
+	PullRequest PullRequestConfiguration `yaml:"pull-request"`
+	// how often a push, which was rejected because the branch has been updated in the meantime, is retried. Defaults to
+	// 3 if not set, 0 disables retries
+	PushRetries *int `yaml:"push-retries"`
+	// whether the branch is created in case it does not exist
+	CreateBranch bool `yaml:"create-branch"`
+	// the branch a created branch is based on, if empty, an orphan branch is created
+	BaseBranch string `yaml:"base-branch"`
+	// author, committer and message of the created commits
+	Commit CommitConfiguration `yaml:"commit"`
+	// whether all dashboards and the folder metadata are exported regardless of their tags, without modifying them in Grafana
+	Backup bool `yaml:"backup"`
+	// whether the versions of a dashboard created in Grafana since its last export are committed individually,
+	// requires the synchronization state to be stored
+	History bool `yaml:"history"`
+}
+
+// Returns the number of retries of a rejected push, which defaults to 3 in case it is not configured.
+func (configuration PushConfiguration) pushRetries() int {
+	if configuration.PushRetries == nil {
+		return defaultPushRetries
+	}
+	return *configuration.PushRetries
 }
 
 // Creates a new Synchronizer instance.
@@ -45,11 +113,14 @@ func NewSynchronizer(options SynchronizeOptions) *Synchronization {
 		"job":              options.JobName,
 		"repository-url":   options.GitRepositoryUrl,
 		"private-key-file": options.PrivateKeyFile,
+		"cache-directory":  options.GitCacheDirectory,
 		"grafana-url":      options.GrafanaUrl,
 	}).Info("Initialize synchronizer job.")
 
 	synchronization.grafanaApi = NewGrafanaApi(options.GrafanaUrl, options.GrafanaToken)
-	synchronization.gitApi = NewGitApi(options.GitRepositoryUrl, options.PrivateKeyFile)
+	// the history of the repository is only required when dashboards are pushed into it or imported from a pinned commit
+	shallow := !options.PushConfiguration.Enable && !IsCommitHash(options.PullConfiguration.GitRevision)
+	synchronization.gitApi = NewGitApi(options.GitRepositoryUrl, options.PrivateKeyFile, options.GitCacheDirectory, shallow)
 
 	return &synchronization
 }
@@ -58,6 +129,22 @@ type Synchronization struct {
 	options    SynchronizeOptions
 	grafanaApi *GrafanaApi
 	gitApi     *GitApi
+	// commit signatures of Grafana users, mapped by their login
+	editors map[string]object.Signature
+	// signs the created commits, nil if commits are not signed
+	signer CommitSigner
+	// whether the push branch has been created by this job and does not exist in the remote repository yet
+	pushBranchCreated bool
+	// opens pull requests for the exported dashboards, nil if they are pushed directly into the push branch
+	pullRequestProvider PullRequestProvider
+	// the open pull request of this job, which is updated instead of opening a new one
+	pullRequest *PullRequest
+	// the branch created for a new pull request
+	pullRequestBranch string
+	// the state of the job's last synchronization, nil if no state is stored
+	state *JobState
+	// the UIDs of dashboards which have been skipped by the import because of conflicting changes
+	conflictingDashboards map[string]bool
 }
 
 // Executes the synchronization using the configuration stored in this struct.
@@ -67,6 +154,39 @@ func (s *Synchronization) Synchronize(dryRun bool) error {
 		"dry-run": strconv.FormatBool(dryRun),
 	}).Info("Starting synchronization.")
 
+	// release the working copy once the job is done
+	defer s.gitApi.Close()
+
+	if s.options.Bidirectional {
+		err := s.options.validateBidirectional()
+		if err != nil {
+			log.WithFields(log.Fields{
+				"error": err,
+				"job":   s.options.JobName,
+			}).Fatal("Invalid configuration for the bidirectional synchronization.")
+			return err
+		}
+	}
+
+	err := s.loadState()
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":   err,
+			"job":     s.options.JobName,
+			"storage": s.options.SyncState.Storage,
+		}).Fatal("Failed to load the synchronization state.")
+		return err
+	}
+
+	// in a bidirectional synchronization, the dashboards are pulled first, so changes made in Git are imported
+	// before dashboards changed in Grafana are exported
+	if s.options.Bidirectional {
+		err = s.pullDashboards(dryRun)
+		if err != nil {
+			return err
+		}
+	}
+
 	// push dashboard into Git
 	if s.options.PushConfiguration.Enable {
 		err := s.pushDashboards(dryRun)
@@ -76,13 +196,25 @@ func (s *Synchronization) Synchronize(dryRun bool) error {
 	}
 
 	// Pull Dashboards from Git
-	if s.options.PullConfiguration.Enable {
+	if s.options.PullConfiguration.Enable && !s.options.Bidirectional {
 		err := s.pullDashboards(dryRun)
 		if err != nil {
 			return err
 		}
 	}
 
+	if !dryRun {
+		err = s.saveState()
+		if err != nil {
+			log.WithFields(log.Fields{
+				"error":   err,
+				"job":     s.options.JobName,
+				"storage": s.options.SyncState.Storage,
+			}).Error("Failed to save the synchronization state.")
+			return err
+		}
+	}
+
 	log.WithFields(log.Fields{
 		"job": s.options.JobName,
 	}).Info("Job was successfully completed.")
@@ -117,9 +249,108 @@ func (s *Synchronization) pushDashboards(dryRun bool) error {
 		}
 	}
 
-	dashboardTag := configuration.TagPattern
+	// determining the extension of the exported files
+	fileExtension, err := dashboardFileExtension(configuration.FileFormat)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":       err,
+			"job":         s.options.JobName,
+			"file-format": configuration.FileFormat,
+		}).Fatal("Invalid file format for the push configuration. Skipping exportation of dashboard.")
+		return err
+	}
+
+	if configuration.Layout != "" && configuration.Layout != "file" && configuration.Layout != "exploded" {
+		err = fmt.Errorf("invalid layout %q, must be 'file' or 'exploded'", configuration.Layout)
+		log.WithFields(log.Fields{
+			"error":  err,
+			"job":    s.options.JobName,
+			"layout": configuration.Layout,
+		}).Fatal("Invalid layout for the push configuration. Skipping exportation of dashboard.")
+		return err
+	}
+
+	tagRemoval, err := tagRemovalMode(configuration.TagRemoval)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":       err,
+			"job":         s.options.JobName,
+			"tag-removal": configuration.TagRemoval,
+		}).Fatal("Invalid tag removal for the push configuration. Skipping exportation of dashboard.")
+		return err
+	}
+
+	if configuration.History && s.options.SyncState.Storage == "" {
+		err = errors.New("the version history requires the synchronization state to be stored")
+		log.WithFields(log.Fields{
+			"error": err,
+			"job":   s.options.JobName,
+		}).Fatal("Invalid history configuration for the push configuration. Skipping exportation of dashboard.")
+		return err
+	}
+
+	// initializing the commit message template
+	messageTemplate, err := configuration.Commit.messageTemplate()
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":   err,
+			"job":     s.options.JobName,
+			"message": configuration.Commit.Message,
+		}).Fatal("Invalid commit configuration for the push configuration. Skipping exportation of dashboard.")
+		return err
+	}
+
+	// loading the key for signing commits
+	s.signer, err = configuration.Commit.Signing.signer()
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":  err,
+			"job":    s.options.JobName,
+			"format": configuration.Commit.Signing.Format,
+		}).Fatal("Failed to load the commit signing key. Skipping exportation of dashboard.")
+		return err
+	}
+
+	// initializing the pull request provider
+	s.pullRequestProvider, err = configuration.PullRequest.provider()
+	if err == nil {
+		_, _, err = configuration.PullRequest.templates()
+	}
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":    err,
+			"job":      s.options.JobName,
+			"provider": configuration.PullRequest.Provider,
+		}).Fatal("Invalid pull request configuration. Skipping exportation of dashboard.")
+		return err
+	}
+
+	// the tags matching the pattern are removed from the exported dashboards
+	syncPattern, err := newTagPattern(configuration.TagPattern, configuration.TagPatternSyntax)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":       err,
+			"job":         s.options.JobName,
+			"tag-pattern": configuration.TagPattern,
+		}).Fatal("Invalid tag pattern for the push configuration. Skipping exportation of dashboard.")
+		return err
+	}
+
+	// a backup exports all dashboards regardless of the sync tag
+	selectionPattern := syncPattern
+	if configuration.Backup {
+		selectionPattern = nil
+	}
+	selection, err := newDashboardSelection(configuration.Selection, selectionPattern)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error": err,
+			"job":   s.options.JobName,
+		}).Fatal("Invalid selection for the push configuration. Skipping exportation of dashboard.")
+		return err
+	}
 
-	resultBoards, err := s.grafanaApi.SearchDashboardsWithTag(dashboardTag)
+	resultBoards, err := s.grafanaApi.SearchDashboards(selection.searchTags(), selection.starred)
 
 	if err != nil {
 		log.WithField("error", err).Fatal("Failed fetching dashboards from Grafana.")
@@ -129,13 +360,51 @@ func (s *Synchronization) pushDashboards(dryRun bool) error {
 		log.WithField("amount", len(resultBoards)).Info("Successfully fetched dashboards.")
 
 		// clone repo from specific branch
-		repository, err := s.gitApi.CloneRepo(configuration.GitBranch)
+		repository, err := s.checkoutPushBranch()
 		if err != nil {
 			log.WithField("error", err).Fatal("Error while cloning repository.")
 			return err
 		}
 
+		// the files to add to the repository, mapped by their path
+		exportFiles := make(map[string]ExportFile)
+		// the state of the exported dashboards, which is recorded once they are pushed
+		var exportedStates []DashboardState
+		// the previous versions of the exported dashboards, which are committed before the current ones
+		var versionCommits []dashboardVersionCommit
+		// the sync tags which are removed in Grafana in case the export changes the dashboards
+		var tagRemovals []pendingTagRemoval
+		// the content hashes of the exported dashboards used by the next import, in case of a bidirectional synchronization
+		syncedHashes := make(map[string]string)
+		// the previous files of renamed or moved dashboards, which are removed
+		movedFiles := make(map[string]ExportFile)
+		// the paths of all dashboards of a backup including skipped and not selected ones, files of other dashboards are removed
+		var backupDashboards map[string]bool
+		if configuration.Backup {
+			backupDashboards, err = s.backupDashboardPaths(resultBoards, len(selection.searchTags()) == 0 && !selection.starred, fileExtension)
+			if err != nil {
+				log.WithField("error", err).Fatal("Failed fetching dashboards from Grafana.")
+				return err
+			}
+		}
+		countSkipped := 0
+		countNotSelected := 0
+
 		for _, board := range resultBoards {
+			// synchronize only selected dashboards
+			folderTitle := board.FolderTitle
+			if folderTitle == "" {
+				folderTitle = "General"
+			}
+			if selected, reason := selection.matches(board.UID, folderTitle, board.Title, board.Tags, board.IsStarred); !selected {
+				log.WithFields(log.Fields{
+					"dashboard-path": folderTitle + "/" + board.Title,
+					"reason":         reason,
+				}).Info("Skipping export because dashboard is not selected.")
+				countNotSelected++
+				continue
+			}
+
 			// get dashboard Object and Properties
 			dashboard, boardProperties := s.grafanaApi.GetDashboardObjectByUID(board.UID)
 
@@ -147,16 +416,58 @@ func (s *Synchronization) pushDashboards(dryRun bool) error {
 						"dashboard-path": folderAndTitle,
 						"filter":         configuration.Filter,
 					}).Info("Skipping export because dashboard does not match the specified filter pattern.")
+					countNotSelected++
 					continue
 				}
 			}
 
-			// delete Tag from dashboard Object
-			var dashboardWithDeletedTag sdk.Board
-			if configuration.PushTags {
-				dashboardWithDeletedTag = dashboard
-			} else {
-				dashboardWithDeletedTag = s.grafanaApi.DeleteTagFromDashboardObjectByID(dashboard, dashboardTag)
+			dashboardPath := boardProperties.FolderTitle + "/" + dashboard.Title
+			if configuration.Layout != "exploded" {
+				dashboardPath += fileExtension
+			}
+
+			// in a bidirectional synchronization, only dashboards changed in Grafana since their last synchronization are
+			// exported, changes made in Git have been imported before
+			if s.options.Bidirectional {
+				if s.conflictingDashboards[dashboard.UID] {
+					log.WithField("dashboard", dashboard.Title).Warn("Dashboard ignored because it has been changed both in Git and in Grafana.")
+					countSkipped++
+					continue
+				}
+				if version, ok := s.lastSyncedVersion(dashboard.UID); ok && version == dashboard.Version {
+					log.WithField("dashboard", dashboard.Title).Info("Dashboard ignored because it has not changed in Grafana since its last synchronization.")
+					countSkipped++
+					continue
+				}
+			}
+
+			// dashboards which have not changed since their last export are skipped quickly
+			if s.state != nil {
+				dashboardState, ok := s.state.Push[dashboard.UID]
+				if ok && dashboardState.GrafanaVersion == dashboard.Version && s.exportedFilesUnchanged(dashboardState, dashboardPath, fileExtension) {
+					log.WithField("dashboard", dashboard.Title).Info("Dashboard ignored because it has not changed since its last export.")
+					countSkipped++
+					continue
+				}
+			}
+
+			// replay the versions created since the last export, before the sync tag is removed in Grafana
+			if configuration.History {
+				commits, err := s.dashboardVersionCommits(dashboard, dashboardPath, fileExtension, syncPattern)
+				if err != nil {
+					log.WithFields(log.Fields{
+						"dashboard": dashboard.Title,
+						"error":     err,
+					}).Fatal("Failed to get the version history of the dashboard.")
+				}
+				versionCommits = append(versionCommits, commits...)
+			}
+
+			// delete the tags matching the pattern from dashboard Object, a backup exports the dashboards unchanged
+			dashboardWithDeletedTag := dashboard
+			var removedTags []string
+			if !configuration.PushTags && !configuration.Backup {
+				dashboardWithDeletedTag.Tags, removedTags = syncPattern.removeFrom(dashboard.Tags)
 			}
 
 			// get folder name and id, required for update processes and git folder structure
@@ -168,32 +479,497 @@ func (s *Synchronization) pushDashboards(dryRun bool) error {
 				log.WithField("error", err).Fatal("Error while parsing dashboard JSON.")
 			}
 
-			// update dashboard with deleted Tag in Grafana
-			log.WithField("dashboard", dashboard.Title).Info("Removing sync tag from dashboard.")
-			if !dryRun {
-				s.grafanaApi.CreateOrUpdateDashboardObjectByID(dashboardJson, folderId, fmt.Sprintf("Deleted '%s' tag", dashboardTag))
+			// update dashboard with deleted Tag in Grafana, which is only saved in case a tag has been removed
+			grafanaVersion := dashboard.Version
+			tagRemoved := len(removedTags) > 0
+			if tagRemoved && tagRemoval == tagRemovalAlways {
+				log.WithField("dashboard", dashboard.Title).Info("Removing sync tag from dashboard.")
+				if !dryRun {
+					statusMessage := s.grafanaApi.CreateOrUpdateDashboardObjectByID(dashboardJson, folderId, tagRemovalMessage(removedTags))
+					if statusMessage.Version != nil {
+						grafanaVersion = uint(*statusMessage.Version)
+					}
+				}
+			} else if tagRemoved && tagRemoval == tagRemovalOnChange {
+				tagRemovals = append(tagRemovals, pendingTagRemoval{
+					Dashboard:     dashboardPath,
+					UID:           dashboard.UID,
+					Title:         dashboard.Title,
+					DashboardJson: dashboardJson,
+					FolderId:      folderId,
+					Tags:          removedTags,
+				})
 			}
 			log.Debug("Dashboard preparation successfully")
 
-			// Add Dashboard to in memory file system
+			if s.options.Bidirectional {
+				syncedJson, err := s.normalizeDashboard(DashboardWithCustomFields{dashboardWithDeletedTag, s.options.JobName})
+				if err != nil {
+					log.WithField("error", err).Fatal("Failed to normalize dashboard.")
+				}
+				syncedHashes[dashboard.UID] = contentHash(syncedJson)
+			}
+
+			// remember dashboard for adding it to the repository
 			log.WithField("dashboard", dashboard.Title).Info("Adding dashboard for synchronization.")
-			s.gitApi.AddFileWithContent(boardProperties.FolderTitle+"/"+dashboard.Title+".json", string(dashboardJson))
+			dashboardFiles, err := s.dashboardExportFiles(dashboardJson, dashboardPath, fileExtension)
+			if err != nil {
+				log.WithField("error", err).Fatal("Error while formatting dashboard JSON.")
+			}
+			for filePath, content := range dashboardFiles {
+				exportFiles[filePath] = ExportFile{
+					Dashboard: dashboardPath,
+					Content:   content,
+					UpdatedBy: boardProperties.UpdatedBy,
+					Updated:   boardProperties.Updated,
+				}
+			}
+
+			// files of the previous export, which are not exported anymore because the dashboard has been renamed or
+			// moved, would be imported again besides the current files
+			for _, previousFile := range s.previousFiles(dashboard.UID) {
+				if previousFile != dashboardPath && !strings.HasPrefix(previousFile, dashboardPath+"/") {
+					movedFiles[previousFile] = ExportFile{
+						Dashboard: dashboardPath,
+						UpdatedBy: boardProperties.UpdatedBy,
+						Updated:   boardProperties.Updated,
+					}
+				}
+			}
+
+			filePaths := make([]string, 0, len(dashboardFiles))
+			for filePath := range dashboardFiles {
+				filePaths = append(filePaths, filePath)
+			}
+			sort.Strings(filePaths)
+			exportedStates = append(exportedStates, DashboardState{
+				UID:            dashboard.UID,
+				GrafanaVersion: grafanaVersion,
+				ContentHash:    exportFilesHash(dashboardFiles),
+				Files:          filePaths,
+			})
+		}
+
+		// the previous path of a dashboard may have been taken by another dashboard in the meantime
+		for filePath, file := range movedFiles {
+			if _, exported := exportFiles[filePath]; !exported {
+				exportFiles[filePath] = file
+			}
 		}
 
 		log.Info("Pushing dashboards to the remote Git repository.")
-		if !dryRun {
-			s.gitApi.CommitWorktree(*repository, dashboardTag)
-			s.gitApi.PushRepo(*repository)
+		countExported := countExportedDashboards(exportFiles) + countSkipped
+
+		// a backup also contains the metadata of all folders, so they can be recreated with their UID
+		if configuration.Backup {
+			err = s.addFolderMetadataFiles(exportFiles, fileExtension)
+			if err != nil {
+				log.WithField("error", err).Fatal("Failed fetching folders from Grafana.")
+				return err
+			}
+		}
+
+		changedDashboards, err := s.commitAndPushFiles(repository, versionCommits, exportFiles, backupDashboards, countExported, messageTemplate, dryRun)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"error":         err,
+				"job":           s.options.JobName,
+				"target-branch": configuration.GitBranch,
+			}).Error("Failed to push dashboards to the remote Git repository.")
+			return err
+		}
+		changedDashboards = withoutFolderMetadata(changedDashboards)
+
+		// the versions of the exported dashboards change by removing their sync tag
+		for uid, version := range s.removeSyncTags(tagRemovals, changedDashboards, dryRun) {
+			for i := range exportedStates {
+				if exportedStates[i].UID == uid {
+					exportedStates[i].GrafanaVersion = version
+				}
+			}
+		}
+		countChanged := len(changedDashboards)
+
+		if s.state != nil && !dryRun {
+			commitId, err, _ := s.gitApi.GetLatestCommitId(*repository)
+			if err != nil {
+				return err
+			}
+			for _, dashboardState := range exportedStates {
+				dashboardState.Commit = commitId
+				s.state.Push[dashboardState.UID] = dashboardState
+
+				// the exported dashboard is the base of the next import, so it is not mistaken for a change in Git
+				if hash, ok := syncedHashes[dashboardState.UID]; ok {
+					s.recordImport(dashboardState.UID, dashboardState.GrafanaVersion, hash, commitId, dashboardState.Files)
+				}
+			}
 		}
 
-		log.Info("Successfully pushed dashboards to the remote Git repository.")
+		if s.pullRequestProvider != nil && countChanged > 0 && !dryRun {
+			err = s.submitPullRequest(changedDashboards, countExported)
+			if err != nil {
+				log.WithFields(log.Fields{
+					"error":         err,
+					"job":           s.options.JobName,
+					"target-branch": configuration.GitBranch,
+				}).Error("Failed to open the pull request.")
+				return err
+			}
+		}
+
+		resultLog := log.WithFields(log.Fields{
+			"selection":    selection.String(),
+			"found":        len(resultBoards),
+			"not-selected": countNotSelected,
+			"exported":     countExported,
+			"changed":      countChanged,
+			"up-to-date":   countExported - countChanged,
+		})
+		if countChanged > 0 {
+			resultLog.Info("Successfully pushed dashboards to the remote Git repository.")
+		} else {
+			resultLog.Info("No dashboards have changed, the Git branch is already up-to-date.")
+		}
 	} else {
-		log.WithField("tag-pattern", configuration.TagPattern).Info("No dashboards found using the configured tag pattern.")
+		log.WithFields(log.Fields{
+			"tag-pattern": configuration.TagPattern,
+			"selection":   selection.String(),
+		}).Info("No dashboards found using the configured tag pattern.")
+	}
+
+	return nil
+}
+
+// Clones the repository and checks out the push branch. In case the branch does not exist and creating it is enabled,
+// it is created locally and will be created in the remote repository by pushing it.
+func (s *Synchronization) checkoutPushBranch() (*git.Repository, error) {
+	configuration := s.options.PushConfiguration
+
+	if s.pullRequestProvider != nil {
+		return s.checkoutPullRequestBranch()
+	}
+
+	if configuration.CreateBranch {
+		exists, err := s.gitApi.BranchExists(configuration.GitBranch)
+		if err != nil {
+			return nil, err
+		}
+		if !exists {
+			log.WithFields(log.Fields{
+				"target-branch": configuration.GitBranch,
+				"base-branch":   configuration.BaseBranch,
+			}).Info("Push branch does not exist and will be created.")
+			s.pushBranchCreated = true
+			return s.gitApi.CreateBranch(configuration.GitBranch, configuration.BaseBranch)
+		}
+	}
+
+	return s.gitApi.CloneRepo(configuration.GitBranch)
+}
+
+// Checks out the branch of the job's open pull request or, if there is none, creates a new uniquely named branch based on the push branch.
+func (s *Synchronization) checkoutPullRequestBranch() (*git.Repository, error) {
+	configuration := s.options.PushConfiguration
+	branchPrefix := configuration.PullRequest.branchPrefix(s.options.JobName)
+
+	if s.pullRequest == nil && s.pullRequestBranch == "" {
+		pullRequest, err := s.pullRequestProvider.FindOpenPullRequest(branchPrefix, configuration.GitBranch)
+		if err != nil {
+			return nil, err
+		}
+
+		if pullRequest != nil {
+			log.WithFields(log.Fields{
+				"pull-request": pullRequest.URL,
+				"branch":       pullRequest.SourceBranch,
+			}).Info("Updating the open pull request of the job.")
+			s.pullRequest = pullRequest
+		} else {
+			s.pullRequestBranch = branchPrefix + time.Now().UTC().Format("20060102-150405")
+		}
+	}
+
+	if s.pullRequest != nil {
+		return s.gitApi.CloneRepo(s.pullRequest.SourceBranch)
+	}
+
+	// the branch already exists in case a previous push attempt was rejected
+	exists, err := s.gitApi.BranchExists(s.pullRequestBranch)
+	if err != nil {
+		return nil, err
+	} else if exists {
+		return s.gitApi.CloneRepo(s.pullRequestBranch)
+	}
+	return s.gitApi.CreateBranch(s.pullRequestBranch, configuration.GitBranch)
+}
+
+// Opens a pull request from the pushed branch into the push branch or updates the job's open pull request. The description
+// of an updated pull request lists all dashboards changed on its branch, including the changes of previous runs.
+func (s *Synchronization) submitPullRequest(changedDashboards []string, countExported int) error {
+	configuration := s.options.PushConfiguration
+	titleTemplate, descriptionTemplate, err := configuration.PullRequest.templates()
+	if err != nil {
+		return err
+	}
+
+	if s.pullRequest != nil {
+		changedFiles, err := s.gitApi.ChangedFilesSince(configuration.GitBranch)
+		if err != nil {
+			return err
+		}
+		changedDashboards = mergeDashboardPaths(changedDashboards, dashboardsOfFiles(changedFiles))
+	}
+
+	data := CommitMessageData{
+		JobName:      s.options.JobName,
+		GrafanaUrl:   s.options.GrafanaUrl,
+		TagPattern:   configuration.TagPattern,
+		Dashboards:   changedDashboards,
+		ChangedCount: len(changedDashboards),
+		TotalCount:   countExported,
+	}
+	title, err := renderCommitMessage(titleTemplate, data)
+	if err != nil {
+		return err
+	}
+	description, err := renderCommitMessage(descriptionTemplate, data)
+	if err != nil {
+		return err
+	}
+
+	if s.pullRequest != nil {
+		err = s.pullRequestProvider.UpdatePullRequest(s.pullRequest, title, description)
+		if err != nil {
+			return err
+		}
+		log.WithField("pull-request", s.pullRequest.URL).Info("Successfully updated the pull request.")
+		return nil
 	}
 
+	pullRequest, err := s.pullRequestProvider.CreatePullRequest(s.pullRequestBranch, configuration.GitBranch, title, description)
+	if err != nil {
+		return err
+	}
+	s.pullRequest = pullRequest
+	log.WithField("pull-request", pullRequest.URL).Info("Successfully opened the pull request.")
 	return nil
 }
 
+// Adds the given files to the push branch, commits and pushes them. Files without content, e.g. the previous files of a
+// renamed dashboard, are removed. In case the push is rejected because the branch has been updated in the meantime, the
+// branch is fetched again and the files are added on top of it until the retries are exhausted.
+// Nothing is committed or pushed if the files are already up-to-date. The given previous versions of the dashboards are
+// committed first. In case of a backup, the dashboard files of all dashboards which are not part of the given backup
+// dashboards are removed. The total count of exported dashboards, including skipped ones, is passed to the commit message.
+// Returns the paths of the changed dashboards.
+func (s *Synchronization) commitAndPushFiles(repository *git.Repository, versionCommits []dashboardVersionCommit, files map[string]ExportFile, backupDashboards map[string]bool, totalCount int, messageTemplate *template.Template, dryRun bool) ([]string, error) {
+	configuration := s.options.PushConfiguration
+
+	for attempt := 0; ; attempt++ {
+		if attempt > 0 {
+			var err error
+			repository, err = s.checkoutPushBranch()
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		var committedVersions []string
+		if !dryRun {
+			var err error
+			committedVersions, err = s.commitDashboardVersions(repository, versionCommits)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		attemptFiles := make(map[string]ExportFile, len(files))
+		paths := make([]string, 0, len(files))
+		for path, file := range files {
+			if file.Content == nil {
+				continue
+			}
+			s.gitApi.AddFileWithContent(path, string(file.Content))
+			attemptFiles[path] = file
+			paths = append(paths, path)
+		}
+
+		// the previous files of renamed or moved dashboards are removed
+		for path, file := range files {
+			if file.Content != nil {
+				continue
+			}
+			removedFiles, err := s.gitApi.RemoveFiles(path)
+			if err != nil {
+				return nil, err
+			}
+			for _, removedFile := range removedFiles {
+				attemptFiles[removedFile] = file
+				paths = append(paths, removedFile)
+			}
+		}
+
+		// files of exploded dashboards which are no longer exported, e.g. of removed panels, are removed
+		explodedDashboards := make(map[string]ExportFile)
+		for path, file := range files {
+			if file.Dashboard != path && file.Content != nil {
+				explodedDashboards[file.Dashboard] = file
+			}
+		}
+		for directory, file := range explodedDashboards {
+			removedFiles, err := s.gitApi.RemoveStaleFiles(directory, files)
+			if err != nil {
+				return nil, err
+			}
+			for _, removedFile := range removedFiles {
+				attemptFiles[removedFile] = ExportFile{Dashboard: directory, UpdatedBy: file.UpdatedBy, Updated: file.Updated}
+				paths = append(paths, removedFile)
+			}
+		}
+
+		// a backup mirrors the Grafana instance, so the files of deleted dashboards and folders are removed
+		if backupDashboards != nil {
+			removedFiles, err := s.gitApi.RemoveStaleDashboardFiles(func(filePath string) bool {
+				return s.keepBackupFile(filePath, files, backupDashboards)
+			})
+			if err != nil {
+				return nil, err
+			}
+			for _, removedFile := range removedFiles {
+				attemptFiles[removedFile] = ExportFile{Dashboard: dashboardOfFile(removedFile)}
+				paths = append(paths, removedFile)
+			}
+		}
+
+		if dryRun {
+			changedFiles, err := s.gitApi.StageFiles(*repository, paths)
+			if err != nil {
+				return nil, err
+			}
+			// the working copy may be reused by the next run, which must not pick up the changes of a dry run
+			err = s.gitApi.ResetWorktree(*repository)
+			if err != nil {
+				return nil, err
+			}
+			return exportedDashboards(attemptFiles, changedFiles), nil
+		}
+
+		changedFiles, err := s.commitFiles(repository, attemptFiles, totalCount, messageTemplate)
+		if err != nil {
+			return nil, err
+		}
+		changedFiles = mergeDashboardPaths(committedVersions, changedFiles)
+		if len(changedFiles) == 0 && !s.pushBranchCreated {
+			log.WithField("target-branch", configuration.GitBranch).Info("Git branch is already up-to-date. Skipping commit and push.")
+			return nil, nil
+		} else if len(changedFiles) == 0 {
+			// an orphan branch without any commit can not be pushed
+			unborn, err := s.gitApi.IsUnborn(*repository)
+			if err != nil {
+				return nil, err
+			} else if unborn {
+				log.WithField("target-branch", configuration.GitBranch).Info("No dashboards have been committed to the created Git branch. Skipping push.")
+				return nil, nil
+			}
+		}
+
+		err = s.gitApi.PushRepo(*repository)
+		if err == nil {
+			return changedFiles, nil
+		} else if !errors.Is(err, ErrPushRejected) {
+			return nil, err
+		} else if attempt >= configuration.pushRetries() {
+			return nil, fmt.Errorf("giving up after %d attempt(s): %w", attempt+1, err)
+		}
+
+		log.WithFields(log.Fields{
+			"target-branch": configuration.GitBranch,
+			"attempt":       attempt + 1,
+			"retries":       configuration.pushRetries(),
+		}).Warn("Push was rejected because the branch has been updated in the meantime. Retrying on top of the updated branch.")
+	}
+}
+
+// Commits the given files, which have been added to the worktree. Depending on the configured grouping, a single commit is created
+// or one commit per dashboard or editor, which is attributed to the Grafana user who edited the dashboards most recently.
+// Groups without changes are not committed. Returns the paths of the committed dashboards.
+func (s *Synchronization) commitFiles(repository *git.Repository, files map[string]ExportFile, totalCount int, messageTemplate *template.Template) ([]string, error) {
+	configuration := s.options.PushConfiguration.Commit
+
+	var committedDashboards []string
+	for _, group := range groupExportFiles(files, configuration.GroupBy) {
+		changedFiles, err := s.gitApi.StageFiles(*repository, group.Paths)
+		if err != nil {
+			return nil, err
+		}
+		if len(changedFiles) == 0 {
+			continue
+		}
+		changedDashboards := exportedDashboards(files, changedFiles)
+
+		message, err := renderCommitMessage(messageTemplate, CommitMessageData{
+			JobName:      s.options.JobName,
+			GrafanaUrl:   s.options.GrafanaUrl,
+			TagPattern:   s.options.PushConfiguration.TagPattern,
+			Editor:       group.UpdatedBy,
+			Dashboards:   changedDashboards,
+			ChangedCount: len(changedDashboards),
+			TotalCount:   totalCount,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		now := time.Now()
+		author := configuration.author(now)
+		if configuration.GroupBy != "" {
+			author = s.editorSignature(group.UpdatedBy, group.Updated)
+		}
+
+		log.WithFields(log.Fields{
+			"author":  author.Name,
+			"changed": len(changedFiles),
+		}).Debug("Committing dashboards.")
+		err = s.gitApi.CommitWorktree(*repository, message, author, configuration.committer(now), s.signer)
+		if err != nil {
+			return nil, err
+		}
+		committedDashboards = append(committedDashboards, changedDashboards...)
+	}
+
+	return committedDashboards, nil
+}
+
+// Returns the signature of the given Grafana user. The user's name and email are used if they can be
+// looked up, which requires admin permissions, otherwise the login is used as name.
+func (s *Synchronization) editorSignature(login string, when time.Time) object.Signature {
+	if s.editors == nil {
+		s.editors = make(map[string]object.Signature)
+	}
+
+	signature, ok := s.editors[login]
+	if !ok {
+		signature = object.Signature{Name: login}
+		user, err := s.grafanaApi.GetUserByLogin(login)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"login": login,
+				"error": err,
+			}).Debug("Could not look up Grafana user, using the login as commit author.")
+		} else if user != nil {
+			if user.Name != "" {
+				signature.Name = user.Name
+			}
+			signature.Email = user.Email
+		}
+		s.editors[login] = signature
+	}
+
+	signature.When = when
+	return signature
+}
+
 // Pulling dashboards from the configured Git and importing them into Grafana.
 func (s *Synchronization) pullDashboards(dryRun bool) error {
 	configuration := s.options.PullConfiguration
@@ -201,9 +977,20 @@ func (s *Synchronization) pullDashboards(dryRun bool) error {
 	log.WithFields(log.Fields{
 		"job":           s.options.JobName,
 		"target-branch": configuration.GitBranch,
+		"revision":      configuration.GitRevision,
+		"tag-range":     configuration.GitTagRange,
 		"filter":        configuration.Filter,
 	}).Info("Starting dashboard synchroization (import) from the Git repository.")
 
+	if configuration.GitRevision != "" && configuration.GitTagRange != "" {
+		err := errors.New("only one of 'git-revision' and 'git-tag-range' can be used")
+		log.WithFields(log.Fields{
+			"error": err,
+			"job":   s.options.JobName,
+		}).Fatal("Invalid pull configuration. Skipping importation of dashboard.")
+		return err
+	}
+
 	// initializing the dashboard filter
 	var regexFilter *regexp.Regexp
 	var err error
@@ -219,6 +1006,25 @@ func (s *Synchronization) pullDashboards(dryRun bool) error {
 		}
 	}
 
+	selection, err := newDashboardSelection(configuration.Selection, nil)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error": err,
+			"job":   s.options.JobName,
+		}).Fatal("Invalid selection for the pull configuration. Skipping importation of dashboard.")
+		return err
+	}
+
+	policy, err := conflictPolicy(configuration.ConflictPolicy)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":           err,
+			"job":             s.options.JobName,
+			"conflict-policy": configuration.ConflictPolicy,
+		}).Fatal("Invalid conflict policy for the pull configuration. Skipping importation of dashboard.")
+		return err
+	}
+
 	// clone and fetch the configured repository
 	repository, err := s.gitApi.CloneRepo(configuration.GitBranch)
 	if err != nil {
@@ -226,17 +1032,48 @@ func (s *Synchronization) pullDashboards(dryRun bool) error {
 		return err
 	}
 
+	// pin the import to the configured revision
+	revision := configuration.GitRevision
+	if configuration.GitTagRange != "" {
+		revision, err = s.gitApi.FindLatestTagInRange(configuration.GitTagRange)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"error":     err,
+				"tag-range": configuration.GitTagRange,
+			}).Fatal("Could not find a tag matching the configured tag range.")
+			return err
+		}
+	}
+	if revision != "" {
+		_, err = s.gitApi.CheckoutRevision(revision)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"error":    err,
+				"revision": revision,
+			}).Fatal("Error while checking out the pinned revision.")
+			return err
+		}
+	}
+
 	commitId, err, _ := s.gitApi.GetLatestCommitId(*repository)
 	if err != nil {
 		return err
 	}
+	commitHash := commitId
+	if revision != "" && revision != commitId {
+		commitId = fmt.Sprintf("%s of revision '%s'", commitId, revision)
+	}
 
 	// stats counter
 	countImport := 0
 	countUpToDate := 0
+	countKept := 0
+	var conflicts []string
+	s.conflictingDashboards = make(map[string]bool)
 
 	// get files from Git repository
-	fileMap := s.gitApi.GetFileContent()
+	fileMap := s.gitApi.GetFileContent(configuration.Jsonnet)
+	duplicates := duplicateDashboardFiles(fileMap)
 
 	// for each folder
 	for folderName, dashboardFiles := range fileMap {
@@ -251,7 +1088,7 @@ func (s *Synchronization) pullDashboards(dryRun bool) error {
 		} else if folder == nil {
 			log.WithField("folder", folderName).Info("Creating Grafana folder.")
 			if !dryRun {
-				folder, err = s.grafanaApi.CreateFolder(folderName)
+				folder, err = s.grafanaApi.CreateFolder(folderName, s.folderUID(folderName))
 				if err != nil {
 					log.WithFields(log.Fields{
 						"folder": folderName,
@@ -263,7 +1100,10 @@ func (s *Synchronization) pullDashboards(dryRun bool) error {
 		}
 
 		// for each dashboard within folder
-		for _, dashboardJson := range dashboardFiles {
+		for fileName, dashboardJson := range dashboardFiles {
+			// the path of the dashboard file or the directory of an exploded dashboard
+			dashboardFile := folderName + "/" + strings.TrimSuffix(fileName, "/")
+
 			// get dashboards from Git and Grafana for comparison
 			dashboard := DashboardWithCustomFields{}
 			err := json.Unmarshal(dashboardJson, &dashboard)
@@ -274,6 +1114,16 @@ func (s *Synchronization) pullDashboards(dryRun bool) error {
 				}).Fatal("Failed to unmarshal dashboard.")
 			}
 
+			// files sharing their UID would overwrite each other on every import, so none of them is imported
+			if duplicateFiles, ok := duplicates[dashboard.UID]; ok {
+				log.WithFields(log.Fields{
+					"dashboard": dashboard.Title,
+					"uid":       dashboard.UID,
+					"files":     duplicateFiles,
+				}).Warn("Dashboard ignored because its UID is contained in multiple files, e.g. in the previous file of a renamed dashboard.")
+				continue
+			}
+
 			// synchronize only dashboards matching the filter
 			if regexFilter != nil {
 				folderAndTitle := folderName + "/" + dashboard.Title
@@ -286,44 +1136,335 @@ func (s *Synchronization) pullDashboards(dryRun bool) error {
 				}
 			}
 
-			//gitDashboardExtended := getDashboardObjectFromRawDashboard(gitRawDashboard)
-			grafanaDashboard, _ := s.grafanaApi.GetDashboardObjectByUID(dashboard.UID)
+			grafanaDashboard, grafanaProperties := s.grafanaApi.GetDashboardObjectByUID(dashboard.UID)
+
+			// synchronize only selected dashboards, whose starred status is taken from Grafana
+			if selected, reason := selection.matches(dashboard.UID, folderName, dashboard.Title, dashboard.Tags, grafanaProperties.IsStarred); !selected {
+				log.WithFields(log.Fields{
+					"dashboard-path": folderName + "/" + dashboard.Title,
+					"reason":         reason,
+				}).Info("Skipping import because dashboard is not selected.")
+				continue
+			}
 
 			// extract the custom tags from the dashboard model
 			syncOrigin := dashboard.SyncOrigin
 
-			// we need to explicitly set certain attributes for comparision
-			// ---
-			// 'Version' and 'Dashboard ID' need to be set equal, as they are different because of import mechanisms
-			grafanaDashboard.Version = dashboard.Version
-			grafanaDashboard.ID = dashboard.ID
+			// both dashboards are normalized for comparison, so instance specific fields like 'Version' and 'Dashboard ID' are ignored.
 			// 'SyncOrigin' need to be set, because custom fields are lost through the import
-			grafanaDashboardExtended := DashboardWithCustomFields{grafanaDashboard, dashboard.SyncOrigin}
+			gitComparable, err := s.normalizeDashboard(dashboard)
+			if err != nil {
+				log.WithFields(log.Fields{
+					"dashboard": dashboard.Title,
+					"error":     err,
+				}).Fatal("Failed to normalize dashboard.")
+			}
+			gitContentHash := contentHash(gitComparable)
+
+			// dashboards which have neither changed in Git nor in Grafana since their last import are skipped quickly
+			if s.state != nil && grafanaDashboard.ID != 0 {
+				dashboardState, ok := s.state.Pull[dashboard.UID]
+				if ok && dashboardState.ContentHash == gitContentHash && dashboardState.GrafanaVersion == grafanaDashboard.Version {
+					log.WithField("dashboard", dashboard.Title).Info("Dashboard ignored because it has not changed since its last import.")
+					countUpToDate++
+					continue
+				}
+			}
+
+			// in a bidirectional synchronization, dashboards exported by this job are not imported again
+			if s.options.Bidirectional && s.isOwnExport(dashboard, grafanaDashboard.Version) {
+				log.WithField("dashboard", dashboard.Title).Info("Dashboard ignored because it has been exported by this job and has not changed since.")
+				countUpToDate++
+				s.recordImport(dashboard.UID, grafanaDashboard.Version, gitContentHash, commitHash, []string{dashboardFile})
+				continue
+			}
+
+			grafanaComparable, err := s.normalizeDashboard(DashboardWithCustomFields{grafanaDashboard, syncOrigin})
+			if err != nil {
+				log.WithFields(log.Fields{
+					"dashboard": dashboard.Title,
+					"error":     err,
+				}).Fatal("Failed to normalize dashboard.")
+			}
 
 			// import dashboard if it differs from the current one
-			if !reflect.DeepEqual(grafanaDashboardExtended, dashboard) {
-				versionMessage := fmt.Sprintf("[SYNC] Synchronized dashboard. Version '%s' from origin '%s' (commit %s).", strconv.Itoa(int(grafanaDashboardExtended.Version)), syncOrigin, commitId)
+			if !bytes.Equal(grafanaComparable, gitComparable) {
+				// check which side has changed since the last import of the dashboard
+				gitChanged, grafanaChanged, err := s.detectChanges(grafanaDashboard, gitContentHash)
+				if err != nil {
+					log.WithFields(log.Fields{
+						"dashboard": dashboard.Title,
+						"error":     err,
+					}).Fatal("Failed to get the version history of the dashboard.")
+				}
+				// in a bidirectional synchronization, changes made only in Grafana are kept, so they are exported afterwards
+				if !gitChanged && (policy != conflictPolicyGitWins || s.options.Bidirectional) {
+					log.WithField("dashboard", dashboard.Title).Info("Dashboard ignored because it has not been changed in Git since its last import, keeping the changes made in Grafana.")
+					countKept++
+					continue
+				} else if gitChanged && grafanaChanged {
+					conflictLog := log.WithFields(log.Fields{
+						"dashboard":       dashboard.Title,
+						"folder":          folderName,
+						"conflict-policy": policy,
+					})
+					switch policy {
+					case conflictPolicyFail:
+						err = fmt.Errorf("dashboard '%s/%s' has been changed both in Git and in Grafana", folderName, dashboard.Title)
+						conflictLog.WithField("error", err).Error("Aborting import because of conflicting changes.")
+						return err
+					case conflictPolicyGrafanaWins:
+						conflictLog.Info("Dashboard ignored because it has also been changed in Grafana, keeping the changes made in Grafana.")
+						conflicts = append(conflicts, folderName+"/"+dashboard.Title)
+						continue
+					case conflictPolicySkipAndReport:
+						conflictLog.Warn("Dashboard ignored because it has been changed both in Git and in Grafana.")
+						conflicts = append(conflicts, folderName+"/"+dashboard.Title)
+						s.conflictingDashboards[dashboard.UID] = true
+						continue
+					default:
+						conflictLog.Warn("Dashboard has been changed both in Git and in Grafana, overwriting the changes made in Grafana.")
+					}
+				}
+
+				versionMessage := fmt.Sprintf("%s Synchronized dashboard from origin '%s' (commit %s, content %s).", syncVersionMessagePrefix, syncOrigin, commitId, gitContentHash)
+				if dashboard.Version > 0 {
+					versionMessage = fmt.Sprintf("%s Synchronized dashboard. Version '%s' from origin '%s' (commit %s, content %s).", syncVersionMessagePrefix, strconv.Itoa(int(dashboard.Version)), syncOrigin, commitId, gitContentHash)
+				}
 
 				log.WithFields(log.Fields{
 					"dashboard": dashboard.Title,
 					"folder":    folderName,
 				}).Info("Importing dashboard into Grafana.")
 				if !dryRun {
-					s.grafanaApi.CreateOrUpdateDashboardObjectByID(dashboardJson, folder.ID, versionMessage)
+					statusMessage := s.grafanaApi.CreateOrUpdateDashboardObjectByID(dashboardJson, folder.ID, versionMessage)
+					if s.state != nil && statusMessage.Version != nil {
+						s.recordImport(dashboard.UID, uint(*statusMessage.Version), gitContentHash, commitHash, []string{dashboardFile})
+					}
 				}
 
 				countImport++
 			} else {
 				log.WithField("dashboard", dashboard.Title).Info("Dashboard ignored because it is already up-to-date.")
 				countUpToDate++
+				if s.state != nil {
+					s.recordImport(dashboard.UID, grafanaDashboard.Version, gitContentHash, commitHash, []string{dashboardFile})
+				}
 			}
 		}
 	}
 
-	log.WithFields(log.Fields{
+	resultLog := log.WithFields(log.Fields{
+		"selection":  selection.String(),
 		"imported":   countImport,
 		"up-to-date": countUpToDate,
-	}).Info("Successfully synchronized dashboards from Git repositroy")
+		"kept":       countKept,
+		"conflicts":  len(conflicts),
+		"duplicates": len(duplicates),
+	})
+	if policy == conflictPolicySkipAndReport && len(conflicts) > 0 {
+		resultLog.WithField("conflicting-dashboards", conflicts).Warn("Synchronized dashboards from Git repository, but skipped dashboards with conflicting changes.")
+	} else if len(duplicates) > 0 {
+		resultLog.Warn("Synchronized dashboards from Git repository, but skipped dashboards whose UID is contained in multiple files.")
+	} else {
+		resultLog.Info("Successfully synchronized dashboards from Git repositroy")
+	}
+
+	return nil
+}
+
+// Returns the configured volatile fields or the default ones if none are configured.
+func (s *Synchronization) volatileFields() []string {
+	if s.options.VolatileFields == nil {
+		return defaultVolatileFields
+	}
+	return s.options.VolatileFields
+}
+
+// Returns the canonical JSON of the given dashboard without its volatile fields, used to compare dashboards.
+func (s *Synchronization) normalizeDashboard(dashboard DashboardWithCustomFields) ([]byte, error) {
+	dashboardJson, err := json.Marshal(dashboard)
+	if err != nil {
+		return nil, err
+	}
+	return CanonicalizeJson(dashboardJson, s.volatileFields(), defaultJsonIndent)
+}
+
+// Returns the files of the given dashboard JSON, mapped by their path, with the fields specific to this Grafana instance
+// removed and formatted as configured for exported files. Exploded dashboards are split into multiple files.
+func (s *Synchronization) dashboardExportFiles(dashboardJson []byte, dashboardPath string, fileExtension string) (map[string][]byte, error) {
+	configuration := s.options.PushConfiguration
+
+	exportJson, err := CanonicalizeJson(dashboardJson, s.volatileFields(), configuration.JsonIndent)
+	if err != nil {
+		return nil, err
+	}
+
+	parts := map[string][]byte{"": exportJson}
+	if configuration.Layout == "exploded" {
+		parts, err = ExplodeDashboard(exportJson)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	files := make(map[string][]byte, len(parts))
+	for partPath, partJson := range parts {
+		content, err := s.formatExportFile(partJson)
+		if err != nil {
+			return nil, err
+		}
+
+		filePath := dashboardPath
+		if partPath != "" {
+			filePath += "/" + partPath + fileExtension
+		}
+		files[filePath] = content
+	}
+	return files, nil
+}
+
+// Returns the given dashboard JSON formatted as configured for exported files.
+func (s *Synchronization) formatExportFile(document []byte) ([]byte, error) {
+	configuration := s.options.PushConfiguration
+
+	// format dashboard, so changes result in readable diffs
+	content, err := CanonicalizeJson(document, nil, configuration.JsonIndent)
+	if err != nil {
+		return nil, err
+	}
+	if configuration.FileFormat == "yaml" {
+		return JsonToYaml(content, configuration.JsonIndent)
+	}
+	return content, nil
+}
+
+// Loads the state of the job's last synchronization from the configured storage.
+func (s *Synchronization) loadState() error {
+	configuration := s.options.SyncState
+	err := configuration.validate(s.options.PushConfiguration)
+	if err != nil {
+		return err
+	}
+
+	switch configuration.Storage {
+	case "local":
+		s.state, err = loadLocalJobState(configuration.path(), s.options.JobName)
+		return err
+	case "repository":
+		branch := s.options.PushConfiguration.GitBranch
+		exists, err := s.gitApi.BranchExists(branch)
+		if err != nil {
+			return err
+		} else if !exists {
+			s.state = newJobState()
+			return nil
+		}
+
+		_, err = s.gitApi.CloneRepo(branch)
+		if err != nil {
+			return err
+		}
+		content, err := s.gitApi.ReadFile(configuration.path())
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		s.state, err = parseJobState(content, s.options.JobName)
+		return err
+	}
+	return nil
+}
+
+// Saves the state of the job's synchronization into the configured storage. In case the state is stored in the repository,
+// it is committed into the push branch if it has changed.
+func (s *Synchronization) saveState() error {
+	configuration := s.options.SyncState
+
+	switch configuration.Storage {
+	case "local":
+		return saveLocalJobState(configuration.path(), s.options.JobName, s.state)
+	case "repository":
+		repository, err := s.checkoutPushBranch()
+		if err != nil {
+			return err
+		}
+		content, err := s.gitApi.ReadFile(configuration.path())
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		updated, err := updateJobState(content, s.options.JobName, s.state)
+		if err != nil {
+			return err
+		}
 
+		s.gitApi.AddFileWithContent(configuration.path(), string(updated))
+		changedFiles, err := s.gitApi.StageFiles(*repository, []string{configuration.path()})
+		if err != nil {
+			return err
+		} else if len(changedFiles) == 0 {
+			return nil
+		}
+
+		commit := s.options.PushConfiguration.Commit
+		now := time.Now()
+		message := fmt.Sprintf("Update synchronization state of job '%s'", s.options.JobName)
+		err = s.gitApi.CommitWorktree(*repository, message, commit.author(now), commit.committer(now), s.signer)
+		if err != nil {
+			return err
+		}
+
+		err = s.gitApi.PushRepo(*repository)
+		if errors.Is(err, ErrPushRejected) {
+			// the state is only used to skip unchanged dashboards, so the next run compares all dashboards again
+			log.WithField("error", err).Warn("Could not push the synchronization state because the branch has been updated in the meantime.")
+			return nil
+		}
+		return err
+	}
 	return nil
 }
+
+// Records the state of the given dashboard after it has been imported from the given files of the given commit.
+func (s *Synchronization) recordImport(uid string, grafanaVersion uint, contentHash string, commit string, files []string) {
+	s.state.Pull[uid] = DashboardState{
+		UID:            uid,
+		GrafanaVersion: grafanaVersion,
+		ContentHash:    contentHash,
+		Commit:         commit,
+		Files:          files,
+	}
+}
+
+// Checks whether the files of the given dashboard, which have been exported by the last synchronization, are unchanged
+// in the worktree and have the given path and extension, which change in case the dashboard or the layout changes.
+func (s *Synchronization) exportedFilesUnchanged(dashboardState DashboardState, dashboardPath string, fileExtension string) bool {
+	if len(dashboardState.Files) == 0 {
+		return false
+	}
+
+	files := make(map[string][]byte, len(dashboardState.Files))
+	for _, path := range dashboardState.Files {
+		if !strings.HasPrefix(path, dashboardPath) || !strings.HasSuffix(path, fileExtension) {
+			return false
+		}
+		content, err := s.gitApi.ReadFile(path)
+		if err != nil {
+			return false
+		}
+		files[path] = content
+	}
+	return exportFilesHash(files) == dashboardState.ContentHash
+}
+
+// Returns the paths of the files the given dashboard has been exported into by the last synchronization. In a bidirectional
+// synchronization, the file or directory it has been imported from is included, as it is located on the same branch.
+func (s *Synchronization) previousFiles(uid string) []string {
+	if s.state == nil {
+		return nil
+	}
+	files := s.state.Push[uid].Files
+	if s.options.Bidirectional {
+		files = append(append([]string{}, files...), s.state.Pull[uid].Files...)
+	}
+	return files
+}
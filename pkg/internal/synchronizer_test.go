@@ -0,0 +1,374 @@
+package internal
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	sdk "github.com/NovatecConsulting/grafana-api-go-sdk"
+	"gopkg.in/src-d/go-git.v4"
+)
+
+// a dashboard stored by the fake Grafana
+type fakeDashboard struct {
+	model    map[string]interface{}
+	folderId int
+	version  int
+}
+
+// a fake Grafana API, which keeps the dashboards and folders in memory
+type fakeGrafana struct {
+	url        string
+	mutex      sync.Mutex
+	nextId     int
+	dashboards map[string]*fakeDashboard
+	folders    []sdk.Folder
+	// the number of times a dashboard has been saved, mapped by its UID
+	saves map[string]int
+}
+
+// starts a fake Grafana API, which supports the requests used by the synchronization
+func startFakeGrafana(t *testing.T) *fakeGrafana {
+	grafana := &fakeGrafana{
+		nextId:     1,
+		dashboards: make(map[string]*fakeDashboard),
+		saves:      make(map[string]int),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/search", grafana.handleSearch)
+	mux.HandleFunc("/api/dashboards/uid/", grafana.handleGetDashboard)
+	mux.HandleFunc("/api/dashboards/db", grafana.handleSaveDashboard)
+	mux.HandleFunc("/api/dashboards/id/", func(writer http.ResponseWriter, request *http.Request) {
+		// the version history is empty, as only the synchronization state is used to detect changes
+		json.NewEncoder(writer).Encode([]sdk.Version{})
+	})
+	mux.HandleFunc("/api/folders", grafana.handleFolders)
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	grafana.url = server.URL
+	return grafana
+}
+
+func (grafana *fakeGrafana) handleSearch(writer http.ResponseWriter, request *http.Request) {
+	grafana.mutex.Lock()
+	defer grafana.mutex.Unlock()
+
+	foundBoards := []sdk.FoundBoard{}
+	if request.URL.Query().Get("page") == "1" {
+		for uid, dashboard := range grafana.dashboards {
+			tags := stringSlice(dashboard.model["tags"])
+			if !containsAll(tags, request.URL.Query()["tag"]) {
+				continue
+			}
+			foundBoards = append(foundBoards, sdk.FoundBoard{
+				ID:          uint(dashboard.model["id"].(int)),
+				UID:         uid,
+				Title:       dashboard.model["title"].(string),
+				Type:        "dash-db",
+				Tags:        tags,
+				FolderID:    dashboard.folderId,
+				FolderTitle: grafana.folderTitle(dashboard.folderId),
+			})
+		}
+	}
+	sort.Slice(foundBoards, func(i, j int) bool {
+		return foundBoards[i].UID < foundBoards[j].UID
+	})
+	json.NewEncoder(writer).Encode(foundBoards)
+}
+
+func (grafana *fakeGrafana) handleGetDashboard(writer http.ResponseWriter, request *http.Request) {
+	grafana.mutex.Lock()
+	defer grafana.mutex.Unlock()
+
+	dashboard, ok := grafana.dashboards[strings.TrimPrefix(request.URL.Path, "/api/dashboards/uid/")]
+	if !ok {
+		writer.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(writer).Encode(map[string]string{"message": "Dashboard not found"})
+		return
+	}
+
+	folderTitle := grafana.folderTitle(dashboard.folderId)
+	if dashboard.folderId == 0 {
+		folderTitle = "General"
+	}
+	json.NewEncoder(writer).Encode(map[string]interface{}{
+		"dashboard": dashboard.model,
+		"meta": sdk.BoardProperties{
+			FolderID:    dashboard.folderId,
+			FolderTitle: folderTitle,
+			UpdatedBy:   "admin",
+			Updated:     time.Now(),
+			Version:     dashboard.version,
+		},
+	})
+}
+
+func (grafana *fakeGrafana) handleSaveDashboard(writer http.ResponseWriter, request *http.Request) {
+	var saveRequest struct {
+		Dashboard map[string]interface{} `json:"dashboard"`
+		FolderId  int                    `json:"folderId"`
+	}
+	json.NewDecoder(request.Body).Decode(&saveRequest)
+
+	uid, _ := saveRequest.Dashboard["uid"].(string)
+	dashboard := grafana.saveDashboard(uid, saveRequest.FolderId, saveRequest.Dashboard)
+	json.NewEncoder(writer).Encode(map[string]interface{}{
+		"status":  "success",
+		"uid":     uid,
+		"id":      dashboard.model["id"],
+		"version": dashboard.version,
+	})
+}
+
+func (grafana *fakeGrafana) handleFolders(writer http.ResponseWriter, request *http.Request) {
+	grafana.mutex.Lock()
+	defer grafana.mutex.Unlock()
+
+	if request.Method == http.MethodPost {
+		var folder sdk.Folder
+		json.NewDecoder(request.Body).Decode(&folder)
+		json.NewEncoder(writer).Encode(grafana.createFolder(folder.Title, folder.UID))
+		return
+	}
+
+	folders := []sdk.Folder{}
+	if request.URL.Query().Get("page") == "1" {
+		folders = grafana.folders
+	}
+	json.NewEncoder(writer).Encode(folders)
+}
+
+// creates or updates the given dashboard, as if it had been saved in the UI, and returns it
+func (grafana *fakeGrafana) saveDashboard(uid string, folderId int, model map[string]interface{}) *fakeDashboard {
+	grafana.mutex.Lock()
+	defer grafana.mutex.Unlock()
+
+	dashboard, ok := grafana.dashboards[uid]
+	if !ok {
+		dashboard = &fakeDashboard{}
+		grafana.dashboards[uid] = dashboard
+		model["id"] = grafana.nextId
+		grafana.nextId++
+	} else {
+		model["id"] = dashboard.model["id"]
+	}
+	dashboard.version++
+	model["uid"] = uid
+	model["version"] = dashboard.version
+	dashboard.model = model
+	dashboard.folderId = folderId
+	grafana.saves[uid]++
+	return dashboard
+}
+
+// creates a dashboard with the given title and tags in the given folder, which is created if it does not exist
+func (grafana *fakeGrafana) addDashboard(folderTitle string, uid string, title string, tags ...string) {
+	grafana.mutex.Lock()
+	folderId := 0
+	if folderTitle != "General" {
+		folderId = grafana.folderId(folderTitle)
+		if folderId == 0 {
+			folderId = grafana.createFolder(folderTitle, "").ID
+		}
+	}
+	grafana.mutex.Unlock()
+
+	grafana.saveDashboard(uid, folderId, map[string]interface{}{"title": title, "tags": tags})
+}
+
+// changes the given field of the given dashboard, which creates a new version
+func (grafana *fakeGrafana) editDashboard(uid string, field string, value interface{}) {
+	dashboard := grafana.dashboard(uid)
+	model := make(map[string]interface{}, len(dashboard.model))
+	for modelField, modelValue := range dashboard.model {
+		model[modelField] = modelValue
+	}
+	model[field] = value
+	grafana.saveDashboard(uid, dashboard.folderId, model)
+}
+
+// returns the given dashboard, nil in case it does not exist
+func (grafana *fakeGrafana) dashboard(uid string) *fakeDashboard {
+	grafana.mutex.Lock()
+	defer grafana.mutex.Unlock()
+	return grafana.dashboards[uid]
+}
+
+// returns the number of times the given dashboard has been saved
+func (grafana *fakeGrafana) saveCount(uid string) int {
+	grafana.mutex.Lock()
+	defer grafana.mutex.Unlock()
+	return grafana.saves[uid]
+}
+
+func (grafana *fakeGrafana) createFolder(title string, uid string) sdk.Folder {
+	if uid == "" {
+		uid = "folder-" + strings.ToLower(title)
+	}
+	folder := sdk.Folder{ID: grafana.nextId, UID: uid, Title: title}
+	grafana.nextId++
+	grafana.folders = append(grafana.folders, folder)
+	return folder
+}
+
+func (grafana *fakeGrafana) folderId(title string) int {
+	for _, folder := range grafana.folders {
+		if folder.Title == title {
+			return folder.ID
+		}
+	}
+	return 0
+}
+
+func (grafana *fakeGrafana) folderTitle(id int) string {
+	for _, folder := range grafana.folders {
+		if folder.ID == id {
+			return folder.Title
+		}
+	}
+	return ""
+}
+
+// helper function to convert the given JSON array into strings
+func stringSlice(value interface{}) []string {
+	var result []string
+	switch values := value.(type) {
+	case []string:
+		result = append(result, values...)
+	case []interface{}:
+		for _, value := range values {
+			result = append(result, value.(string))
+		}
+	}
+	return result
+}
+
+// helper function to check whether the given values contain all of the required values
+func containsAll(values []string, required []string) bool {
+	for _, requiredValue := range required {
+		found := false
+		for _, value := range values {
+			found = found || value == requiredValue
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// a bare repository on the local filesystem, which is used as remote repository
+type testRemote struct {
+	url     string
+	keyFile string
+}
+
+// creates an empty bare repository and a private key, which is required although it is not used by local repositories
+func newTestRemote(t *testing.T) testRemote {
+	directory := t.TempDir()
+	_, err := git.PlainInit(filepath.Join(directory, "remote.git"), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyFile := filepath.Join(directory, "id_rsa")
+	keyPem := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	if err = ioutil.WriteFile(keyFile, keyPem, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	return testRemote{url: filepath.Join(directory, "remote.git"), keyFile: keyFile}
+}
+
+// commits the given files into the given branch of the remote repository, which is created if it does not exist
+func (remote testRemote) commitFiles(t *testing.T, branch string, files map[string]string) {
+	gitApi := NewGitApi(remote.url, remote.keyFile, "", false)
+	exists, err := gitApi.BranchExists(branch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var repository *git.Repository
+	if exists {
+		repository, err = gitApi.CloneRepo(branch)
+	} else {
+		repository, err = gitApi.CreateBranch(branch, "")
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var paths []string
+	for filePath, content := range files {
+		gitApi.AddFileWithContent(filePath, content)
+		paths = append(paths, filePath)
+	}
+	if _, err = gitApi.StageFiles(*repository, paths); err != nil {
+		t.Fatal(err)
+	}
+	signature := CommitConfiguration{}.author(time.Now())
+	if err = gitApi.CommitWorktree(*repository, "Add test files", signature, signature, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err = gitApi.PushRepo(*repository); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// returns the contents of all files of the given branch of the remote repository, mapped by their path
+func (remote testRemote) files(t *testing.T, branch string) map[string]string {
+	gitApi := NewGitApi(remote.url, remote.keyFile, "", false)
+	if _, err := gitApi.CloneRepo(branch); err != nil {
+		t.Fatal(err)
+	}
+
+	files := make(map[string]string)
+	var readDirectory func(directory string)
+	readDirectory = func(directory string) {
+		entries, err := gitApi.fileSystem.ReadDir(directory)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, entry := range entries {
+			entryPath := strings.TrimPrefix(directory+"/"+entry.Name(), "./")
+			if entry.IsDir() {
+				if entry.Name() != git.GitDirName {
+					readDirectory(entryPath)
+				}
+				continue
+			}
+			content, err := gitApi.ReadFile(entryPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			files[entryPath] = string(content)
+		}
+	}
+	readDirectory(".")
+	return files
+}
+
+// runs a synchronization using the given options, which is expected to succeed
+func synchronize(t *testing.T, options SynchronizeOptions) {
+	t.Helper()
+	if err := NewSynchronizer(options).Synchronize(false); err != nil {
+		t.Fatal(err)
+	}
+}
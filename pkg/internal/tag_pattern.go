@@ -0,0 +1,91 @@
+package internal
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// the syntaxes of a tag pattern
+const (
+	tagPatternSyntaxExact = "exact"
+	tagPatternSyntaxGlob  = "glob"
+	tagPatternSyntaxRegex = "regex"
+)
+
+// a pattern matching the tags of dashboards
+type tagPattern struct {
+	pattern string
+	syntax  string
+	// the compiled pattern, nil in case the tag is matched exactly
+	regex *regexp.Regexp
+}
+
+// Parses the given tag pattern using the given syntax, which defaults to an exact match. Glob patterns support "*"
+// and "?" as wildcards, regular expressions have to match the whole tag. Returns nil in case the pattern is empty.
+func newTagPattern(pattern string, syntax string) (*tagPattern, error) {
+	if pattern == "" {
+		return nil, nil
+	}
+
+	switch syntax {
+	case "", tagPatternSyntaxExact:
+		return &tagPattern{pattern: pattern, syntax: tagPatternSyntaxExact}, nil
+	case tagPatternSyntaxGlob:
+		expression := regexp.QuoteMeta(pattern)
+		expression = strings.ReplaceAll(expression, `\*`, ".*")
+		expression = strings.ReplaceAll(expression, `\?`, ".")
+		return &tagPattern{pattern: pattern, syntax: syntax, regex: regexp.MustCompile("^(?:" + expression + ")$")}, nil
+	case tagPatternSyntaxRegex:
+		regex, err := regexp.Compile("^(?:" + pattern + ")$")
+		if err != nil {
+			return nil, fmt.Errorf("invalid tag pattern: %w", err)
+		}
+		return &tagPattern{pattern: pattern, syntax: syntax, regex: regex}, nil
+	default:
+		return nil, fmt.Errorf("invalid tag pattern syntax %q, must be '%s', '%s' or '%s'", syntax,
+			tagPatternSyntaxExact, tagPatternSyntaxGlob, tagPatternSyntaxRegex)
+	}
+}
+
+// Checks whether the pattern matches a single tag, so it can be passed to the Grafana search.
+func (p *tagPattern) isExact() bool {
+	return p.regex == nil
+}
+
+// Checks whether the given tag matches the pattern.
+func (p *tagPattern) matches(tag string) bool {
+	if p.regex == nil {
+		return tag == p.pattern
+	}
+	return p.regex.MatchString(tag)
+}
+
+// Returns the given tags without the ones matching the pattern, which are returned separately. The given tags are
+// not modified.
+func (p *tagPattern) removeFrom(tags []string) ([]string, []string) {
+	if p == nil {
+		return tags, nil
+	}
+
+	var remaining, removed []string
+	for _, tag := range tags {
+		if p.matches(tag) {
+			removed = append(removed, tag)
+		} else {
+			remaining = append(remaining, tag)
+		}
+	}
+	if removed == nil {
+		return tags, nil
+	}
+	return remaining, removed
+}
+
+// Returns the pattern, including its syntax unless the tag is matched exactly.
+func (p *tagPattern) String() string {
+	if p.isExact() {
+		return p.pattern
+	}
+	return fmt.Sprintf("%s %q", p.syntax, p.pattern)
+}
@@ -0,0 +1,73 @@
+package internal
+
+import (
+	"fmt"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// modes when the sync tag of an exported dashboard is removed in Grafana, in case it is not pushed
+const (
+	tagRemovalAlways     = "always"
+	tagRemovalExportOnly = "export-only"
+	tagRemovalOnChange   = "on-change"
+)
+
+// the removal of the sync tag of an exported dashboard in Grafana, which is deferred until it is known whether the
+// export has changed the dashboard
+type pendingTagRemoval struct {
+	// the path of the exported dashboard
+	Dashboard string
+	UID       string
+	Title     string
+	// the dashboard JSON without the sync tag and the folder it is saved into
+	DashboardJson []byte
+	FolderId      int
+	// the removed tags
+	Tags []string
+}
+
+// Returns the configured tag removal mode or the default mode if none is configured.
+func tagRemovalMode(mode string) (string, error) {
+	switch mode {
+	case "":
+		return tagRemovalAlways, nil
+	case tagRemovalAlways, tagRemovalExportOnly, tagRemovalOnChange:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("invalid tag removal %q, must be '%s', '%s' or '%s'", mode,
+			tagRemovalAlways, tagRemovalExportOnly, tagRemovalOnChange)
+	}
+}
+
+// Removes the sync tag in Grafana from the given dashboards which have been changed by the export.
+// Returns the Grafana versions of the updated dashboards, mapped by their UID.
+func (s *Synchronization) removeSyncTags(removals []pendingTagRemoval, changedDashboards []string, dryRun bool) map[string]uint {
+	changed := make(map[string]bool, len(changedDashboards))
+	for _, dashboard := range changedDashboards {
+		changed[dashboard] = true
+	}
+
+	versions := make(map[string]uint)
+	for _, removal := range removals {
+		if !changed[removal.Dashboard] {
+			continue
+		}
+
+		log.WithField("dashboard", removal.Title).Info("Removing sync tag from dashboard.")
+		if dryRun {
+			continue
+		}
+		statusMessage := s.grafanaApi.CreateOrUpdateDashboardObjectByID(removal.DashboardJson, removal.FolderId, tagRemovalMessage(removal.Tags))
+		if statusMessage.Version != nil {
+			versions[removal.UID] = uint(*statusMessage.Version)
+		}
+	}
+	return versions
+}
+
+// Returns the version message of a dashboard whose given tags have been removed.
+func tagRemovalMessage(tags []string) string {
+	return fmt.Sprintf("Deleted '%s' tag", strings.Join(tags, "', '"))
+}
@@ -32,6 +32,32 @@ func main() {
 		Action: func(c *cli.Context) error {
 			return synchronizeDashboards(c)
 		},
+		Commands: []*cli.Command{
+			{
+				Name:  "restore",
+				Usage: "imports a single dashboard with its content at the given commit or tag of the job's branch",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:     "job",
+						Usage:    "the name of the job whose repository and branch are used",
+						Required: true,
+					},
+					&cli.StringFlag{
+						Name:     "dashboard",
+						Usage:    "the UID of the dashboard or its path in the repository, e.g. \"Folder/Dashboard.json\"",
+						Required: true,
+					},
+					&cli.StringFlag{
+						Name:     "revision",
+						Usage:    "the commit or tag to restore the dashboard from",
+						Required: true,
+					},
+				},
+				Action: func(c *cli.Context) error {
+					return restoreDashboard(c)
+				},
+			},
+		},
 	}
 
 	err := app.Run(os.Args)
@@ -40,14 +66,18 @@ func main() {
 	}
 }
 
-// Starts the synchronization of the Grafana dashboards.
-func synchronizeDashboards(c *cli.Context) error {
-	// setup logger
+// Configures the log format according to the given flags.
+func setupLogger(c *cli.Context) {
 	if c.Bool("log-as-json") {
 		log.SetFormatter(&log.JSONFormatter{})
 	} else {
 		log.SetFormatter(&log.TextFormatter{ForceColors: true})
 	}
+}
+
+// Starts the synchronization of the Grafana dashboards.
+func synchronizeDashboards(c *cli.Context) error {
+	setupLogger(c)
 
 	log.Info("Synchronizing Grafana dashboards...")
 
@@ -63,15 +93,51 @@ func synchronizeDashboards(c *cli.Context) error {
 	}
 
 	// do the synchronization
+	failedJobs := 0
 	for _, element := range *input {
 		synchronizer := internal.NewSynchronizer(element)
-		synchronizer.Synchronize(c.Bool("dry-run"))
+		err := synchronizer.Synchronize(c.Bool("dry-run"))
+		if err != nil {
+			log.WithFields(log.Fields{
+				"job":   element.JobName,
+				"error": err,
+			}).Error("Job failed.")
+			failedJobs++
+		}
+	}
+
+	if failedJobs > 0 {
+		return fmt.Errorf("%d job(s) failed", failedJobs)
 	}
 
 	log.Info("Synchronization completed.")
 	return nil
 }
 
+// Restores a single dashboard of the given job from the given revision of its Git repository.
+func restoreDashboard(c *cli.Context) error {
+	setupLogger(c)
+
+	if c.Bool("dry-run") {
+		log.Info("DRY-RUN : The application will NOT perform any changes to Grafana due to the dry-run flag!")
+	}
+
+	// read configuration
+	input, err := readConf(c.String("config"))
+	if err != nil {
+		log.WithField("error", err).Fatal("Error while reading configuration file.")
+		return err
+	}
+
+	for _, element := range *input {
+		if element.JobName == c.String("job") {
+			synchronizer := internal.NewSynchronizer(element)
+			return synchronizer.Restore(c.String("dashboard"), c.String("revision"), c.Bool("dry-run"))
+		}
+	}
+	return fmt.Errorf("job %q does not exist in the configuration", c.String("job"))
+}
+
 // Reads the given file and parses it into a struct representing the configuration to use.
 func readConf(filename string) (*[]internal.SynchronizeOptions, error) {
 	log.WithField("file", filename).Info("Reading configuration file...")